@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// built-in action names
+const (
+	actionCopy    = "copy"
+	actionOpen    = "open"
+	actionPlay    = "play"
+	actionCompose = "compose"
+	actionPrint   = "print"
+	// actionCustom shows a menu with the user-defined actions
+	actionCustom = "custom"
+	// actionAsk shows a menu with all the actions
+	actionAsk = "ask"
+)
+
+// builtinActions are the built-in actions, in the order shown in menus
+var builtinActions = []string{actionOpen, actionCopy, actionPlay, actionLater, actionRead, actionSnapshot, actionCompose, actionPrint}
+
+var errUnknownAction = errors.New("unknown action")
+
+// Action is an operation applied to the selected items, with the options
+// of the output
+type Action interface {
+	Name() string
+	Run(ctx context.Context, o *Options, items []Match) error
+}
+
+// actions holds the registered actions by name
+var actions = make(map[string]Action)
+
+// customActions are the names of the user-defined actions, in the order
+// of the config file
+var customActions []string
+
+// multiAction is implemented by actions that can run on several items
+type multiAction interface {
+	Multi() bool
+}
+
+// allowsMulti reports whether the action can run on several items, the
+// built-in actions can
+func allowsMulti(a Action) bool {
+	if m, ok := a.(multiAction); ok {
+		return m.Multi()
+	}
+
+	return true
+}
+
+// registerAction adds the action to the registry, replacing any action
+// with the same name
+func registerAction(a Action) {
+	actions[a.Name()] = a
+}
+
+// lookupAction returns the registered action with the name
+func lookupAction(name string) (Action, error) {
+	a, ok := actions[name]
+	if !ok {
+		if path, err := findPlugin(name); err == nil {
+			return &pluginAction{name: name, path: path}, nil
+		}
+		return nil, fmt.Errorf("%w: %q (available: %s)", errUnknownAction, name, strings.Join(actionNames(), ", "))
+	}
+
+	return a, nil
+}
+
+// actionNames returns the names of the registered actions, sorted
+func actionNames() []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// urlAction is an action that runs a function for each selected URL
+type urlAction struct {
+	fn   func(url string) error
+	name string
+}
+
+func (a *urlAction) Name() string { return a.name }
+
+func (a *urlAction) Run(_ context.Context, _ *Options, items []Match) error {
+	for i := range items {
+		if err := a.fn(items[i].Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openAction opens URLs in the browser and emails in the mail client, unless
+// their scheme has an opener in the config
+type openAction struct{}
+
+func (openAction) Name() string { return actionOpen }
+
+func (openAction) Run(_ context.Context, _ *Options, items []Match) error {
+	for i := range items {
+		open := openURL
+		if items[i].Type == typeEmail && openWithCmd(items[i].Value) == nil {
+			open = composeEmail
+		}
+		if err := open(items[i].Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyAction copies the selected items to the clipboard, one per line
+type copyAction struct{}
+
+func (copyAction) Name() string { return actionCopy }
+
+func (copyAction) Run(_ context.Context, o *Options, items []Match) error {
+	values := make([]string, 0, len(items))
+	for i := range items {
+		values = append(values, o.outputValue(items[i].Value))
+	}
+
+	return copyURL(strings.Join(values, "\n"))
+}
+
+// printAction writes the selected items to STDOUT, one per line
+type printAction struct{}
+
+func (printAction) Name() string { return actionPrint }
+
+func (printAction) Run(_ context.Context, o *Options, items []Match) error {
+	for i := range items {
+		if _, err := fmt.Println(o.outputValue(items[i].Value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExecAction runs a command for each selected item. The placeholder {url}
+// in the arguments is replaced with the item, when there is none the item
+// is appended as the last argument.
+type ExecAction struct {
+	ActionName string
+	Command    []string
+	// Confirm asks before running the action
+	Confirm bool
+	// multi allows running the action on several items
+	multi bool
+}
+
+func (a *ExecAction) Name() string { return a.ActionName }
+
+func (a *ExecAction) Multi() bool { return a.multi }
+
+// cmd returns the command for the URL
+func (a *ExecAction) cmd(ctx context.Context, url string) *exec.Cmd {
+	args := make([]string, 0, len(a.Command)+1)
+	var replaced bool
+	for _, arg := range a.Command {
+		if strings.Contains(arg, "{url}") {
+			arg = strings.ReplaceAll(arg, "{url}", url)
+			replaced = true
+		}
+		args = append(args, arg)
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+
+	return exec.CommandContext(ctx, args[0], args[1:]...)
+}
+
+func (a *ExecAction) Run(ctx context.Context, _ *Options, items []Match) error {
+	if len(a.Command) == 0 {
+		return fmt.Errorf("action %q: %w", a.ActionName, errMissingCmd)
+	}
+
+	if a.Confirm && !confirm(fmt.Sprintf("Run %s on %d item(s)?", a.ActionName, len(items))) {
+		printInfo("action cancelled")
+		return nil
+	}
+
+	for i := range items {
+		if err := checkSafeURL(items[i].Value); err != nil {
+			return fmt.Errorf("action %q: %w", a.ActionName, err)
+		}
+		cmd := a.cmd(ctx, items[i].Value)
+		log.Printf("running action %q: %s", a.ActionName, cmd.Args)
+		if err := startCmd(cmd); err != nil {
+			return fmt.Errorf("action %q: %w", a.ActionName, err)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	registerAction(copyAction{})
+	registerAction(openAction{})
+	registerAction(snapshotAction{})
+	registerAction(readAction{})
+	registerAction(&urlAction{name: actionPlay, fn: playURL})
+	registerAction(&urlAction{name: actionCompose, fn: composeEmail})
+	registerAction(printAction{})
+}
+
+// selectedAction returns the name of the action chosen with the flags, or
+// an empty string when there is none
+func selectedAction() string {
+	switch {
+	case actionFlag != "":
+		return actionFlag
+	case askActionFlag:
+		return actionAsk
+	case openFlag:
+		return actionOpen
+	case copyFlag:
+		return actionCopy
+	case playFlag:
+		return actionPlay
+	case laterFlag:
+		return actionLater
+	case readFlag:
+		return actionRead
+	}
+
+	return ""
+}
+
+// menuActions returns the actions listed in the action menu, or nil if the
+// name is not an action menu. compose is only listed for emails.
+func menuActions(name string, selected []Match) []string {
+	switch name {
+	case actionCustom:
+		return customActions
+	case actionAsk:
+		names := make([]string, 0, len(builtinActions)+len(customActions))
+		for _, n := range builtinActions {
+			if n == actionCompose && !hasType(selected, typeEmail) {
+				continue
+			}
+			names = append(names, n)
+		}
+		return append(names, customActions...)
+	}
+
+	return nil
+}
+
+// hasType reports whether any of the items is of the type
+func hasType(items []Match, t string) bool {
+	for i := range items {
+		if items[i].Type == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pickAction shows a menu with the actions and returns the chosen one
+func pickAction(names []string) (string, bool) {
+	m := newMenu("Action>")
+	output, err := m.show(strings.Join(names, "\n"))
+	exitOnMenuError(err)
+
+	name := strings.TrimSpace(output)
+	return name, name != ""
+}
+
+// confirm asks the question with a yes/no menu
+func confirm(question string) bool {
+	m := newMenu(question)
+	output, err := m.show("no\nyes")
+	if errors.Is(err, errMenuCanceled) {
+		return false
+	}
+	logErrAndExit(err)
+
+	return strings.TrimSpace(output) == "yes"
+}
+
+// actionPrompt returns the menu prompt for the action
+func actionPrompt(name string) string {
+	if name == "" || name == actionAsk || name == actionCustom {
+		return "GoURLs>"
+	}
+
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r) + "URL>"
+}
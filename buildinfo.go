@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// set at build time with -ldflags "-X main.buildCommit=... -X main.buildDate=..."
+var (
+	buildCommit string
+	buildDate   string
+)
+
+// backends are the external commands used by optional features
+var backends = []struct {
+	command string
+	feature string
+}{
+	{"tesseract", "ocr"},
+	{"zbarimg", "qr"},
+	{"pdftotext", "pdf"},
+	{"xz", "xz"},
+	{"zstd", "zstd"},
+	{"yt-dlp", "media-info"},
+	{"tmux", "tmux"},
+	{"gdbus", "portal"},
+	{"busctl", "portal"},
+}
+
+// BuildInfo describes the build and the environment, for bug reports
+type BuildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	Date      string   `json:"date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	Platform  string   `json:"platform"`
+	Menu      string   `json:"menu"`
+	Opener    string   `json:"opener"`
+	Clipboard string   `json:"clipboard"`
+	Backends  []string `json:"backends"`
+	Missing   []string `json:"missing_backends"`
+	Modified  bool     `json:"modified,omitempty"`
+}
+
+// buildInfo returns the build metadata, from the ldflags or else from the
+// VCS information embedded by the go tool
+func buildInfo() BuildInfo {
+	b := BuildInfo{
+		Version:   appVersion,
+		Commit:    buildCommit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Platform:  platform.Name,
+		Menu:      menu.Command,
+		Opener:    xdgOpen,
+		Clipboard: "clipboard package",
+		Backends:  []string{},
+		Missing:   []string{},
+	}
+	if len(platform.Copy) > 0 {
+		b.Clipboard = platform.Copy[0]
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && b.Commit == "":
+				b.Commit = s.Value
+			case s.Key == "vcs.time" && b.Date == "":
+				b.Date = s.Value
+			case s.Key == "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	for _, be := range backends {
+		name := be.command
+		if be.feature != be.command {
+			name += " (" + be.feature + ")"
+		}
+		if _, err := exec.LookPath(be.command); err != nil {
+			b.Missing = append(b.Missing, name)
+			continue
+		}
+		b.Backends = append(b.Backends, name)
+	}
+
+	return b
+}
+
+// printVersion prints the version and build information, as JSON with
+// --json
+func printVersion() {
+	b := buildInfo()
+	if jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		logErrAndExit(enc.Encode(b))
+		return
+	}
+
+	commit := b.Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	if b.Modified {
+		commit += " (modified)"
+	}
+
+	fmt.Print(version())
+	fmt.Printf("commit:    %s\n", commit)
+	if b.Date != "" {
+		fmt.Printf("date:      %s\n", b.Date)
+	}
+	fmt.Printf("go:        %s\n", b.GoVersion)
+	fmt.Printf("platform:  %s\n", b.Platform)
+	fmt.Printf("menu:      %s\n", b.Menu)
+	fmt.Printf("opener:    %s\n", b.Opener)
+	fmt.Printf("clipboard: %s\n", b.Clipboard)
+	fmt.Printf("backends:  %s\n", strings.Join(b.Backends, ", "))
+	fmt.Printf("missing:   %s\n", strings.Join(b.Missing, ", "))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// pageCanonical returns the canonical URL declared by the HTML page, with
+// <link rel=canonical> or og:url, or "" if there is none
+func pageCanonical(page string) string {
+	for _, tag := range previewLink.FindAllString(page, -1) {
+		if inList(strings.Fields(strings.ToLower(htmlAttr(tag, "rel"))), "canonical") {
+			if href := htmlAttr(tag, "href"); href != "" {
+				return href
+			}
+		}
+	}
+
+	for _, tag := range previewMeta.FindAllString(page, -1) {
+		if strings.EqualFold(htmlAttr(tag, "property"), "og:url") {
+			return htmlAttr(tag, "content")
+		}
+	}
+
+	return ""
+}
+
+// canonicalURL fetches the page and returns its canonical URL, or the URL
+// itself when the page declares none
+func canonicalURL(client *http.Client, rawURL string) (string, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return rawURL, nil
+	}
+
+	resp, b, err := fetchBody(client, u.String(), previewMaxPage)
+	if err != nil {
+		return "", err
+	}
+	if !isHTML(resp) {
+		return rawURL, nil
+	}
+
+	if c := resolveRef(resp.Request.URL, pageCanonical(string(b))); c != "" && c != resp.Request.URL.String() {
+		return c, nil
+	}
+
+	return rawURL, nil
+}
+
+// canonicalizeItems replaces the page URLs with their canonical URL, so the
+// AMP, mobile and tracking variants of a page are the same item
+func canonicalizeItems(items []Match) {
+	client := newHTTPClient(expandTimeout)
+	canonical := resolveAll(itemValues(items), func(s string) (string, error) {
+		return canonicalURL(client, s)
+	})
+
+	for i := range items {
+		items[i].Value = canonical[items[i].Value]
+	}
+}
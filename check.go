@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkTimeout = 10 * time.Second
+	checkWorkers = 32
+	// checkMaxDocument is the size limit of documents searched for anchors
+	checkMaxDocument = 10 << 20
+	// checkMaxRetryAfter caps the delay asked by servers with Retry-After
+	checkMaxRetryAfter = 30 * time.Second
+)
+
+// values of --fail-on
+const (
+	failOnBroken = "broken"
+	failOnAll    = "all"
+	failOnNone   = "none"
+)
+
+var failOnValues = []string{failOnBroken, failOnAll, failOnNone}
+
+var errBrokenLinks = errors.New("broken links found")
+
+// validateFailOn checks the value of the --fail-on flag
+func validateFailOn(s string) error {
+	if inList(failOnValues, s) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: --fail-on %q (valid: %s)", errInvalidFlag, s, strings.Join(failOnValues, ", "))
+}
+
+// checkResult is the outcome of checking a URL
+type checkResult struct {
+	Err error
+	// MissingFragment is the fragment of the URL not found in the document
+	MissingFragment string
+	// RetryAfter is the delay asked by the server before retrying
+	RetryAfter time.Duration
+	Status     int
+	// Resolved is set when only the host was resolved, with --check-dns
+	Resolved bool
+}
+
+// broken reports whether the URL could not be fetched or returned an error
+// status
+func (c *checkResult) broken() bool {
+	return c.Err != nil || c.Status >= http.StatusBadRequest || c.MissingFragment != ""
+}
+
+// transient reports whether the failure may go away by retrying later:
+// timeouts, network errors, server errors and rate limiting
+func (c *checkResult) transient() bool {
+	if c.Err != nil {
+		var dnsErr *net.DNSError
+		return !errors.As(c.Err, &dnsErr) || !dnsErr.IsNotFound
+	}
+
+	return c.Status >= http.StatusInternalServerError || c.Status == http.StatusTooManyRequests
+}
+
+// String returns the status code, or the error when the request failed,
+// telling transient and permanent failures apart
+func (c *checkResult) String() string {
+	var s string
+	switch {
+	case c.Err != nil:
+		s = c.Err.Error()
+	case c.Resolved:
+		s = "resolved"
+	default:
+		s = fmt.Sprintf("%d %s", c.Status, http.StatusText(c.Status))
+		if c.MissingFragment != "" {
+			s += fmt.Sprintf(", missing anchor #%s", c.MissingFragment)
+		}
+	}
+
+	switch {
+	case !c.broken():
+		return s
+	case c.transient():
+		return s + " (transient)"
+	default:
+		return s + " (permanent)"
+	}
+}
+
+// dnsChecker resolves the hosts of the URLs, looking up each host once
+type dnsChecker struct {
+	hosts map[string]*dnsLookup
+	mu    sync.Mutex
+}
+
+// dnsLookup is the result of resolving a host, shared by its URLs
+type dnsLookup struct {
+	err  error
+	once sync.Once
+}
+
+func newDNSChecker() *dnsChecker {
+	return &dnsChecker{hosts: make(map[string]*dnsLookup)}
+}
+
+// check resolves the host of the URL without making HTTP requests
+func (d *dnsChecker) check(rawURL string) *checkResult {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return &checkResult{Err: err}
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return &checkResult{Resolved: true}
+	}
+
+	d.mu.Lock()
+	l, ok := d.hosts[host]
+	if !ok {
+		l = &dnsLookup{}
+		d.hosts[host] = l
+	}
+	d.mu.Unlock()
+
+	l.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		defer cancel()
+		_, l.err = net.DefaultResolver.LookupHost(ctx, host)
+	})
+
+	if l.err != nil {
+		// forget transient failures so retries resolve the host again
+		var dnsErr *net.DNSError
+		if !errors.As(l.err, &dnsErr) || !dnsErr.IsNotFound {
+			d.mu.Lock()
+			if d.hosts[host] == l {
+				delete(d.hosts, host)
+			}
+			d.mu.Unlock()
+		}
+		return &checkResult{Err: l.err}
+	}
+
+	return &checkResult{Resolved: true}
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+
+	return min(time.Duration(secs)*time.Second, checkMaxRetryAfter)
+}
+
+// checkWithRetry checks the URL, retrying transient failures with
+// exponential backoff
+func checkWithRetry(rawURL string, check func(string) *checkResult) *checkResult {
+	delay := backoffFlag
+	for attempt := 0; ; attempt++ {
+		c := check(rawURL)
+		if !c.broken() || !c.transient() || attempt >= retriesFlag {
+			return c
+		}
+
+		wait := max(delay, c.RetryAfter)
+		log.Printf("%s: %s, retrying in %s", rawURL, c, wait)
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// checkable reports whether the item is a URL that can be fetched
+func checkable(m *Match) bool {
+	u, err := parseURL(m.Value)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// checkedFragment returns the fragment of the URL that should exist in the
+// document, skipping the ones used for routing by single page apps
+func checkedFragment(u *url.URL) string {
+	f := u.Fragment
+	if f == "" || f == "top" || strings.HasPrefix(f, "/") || strings.HasPrefix(f, "!") ||
+		strings.HasPrefix(f, ":~:") {
+		return ""
+	}
+
+	return f
+}
+
+// hasAnchor reports whether the HTML document has an element with the
+// fragment as id or name
+func hasAnchor(r io.Reader, fragment string) (bool, error) {
+	b, err := io.ReadAll(io.LimitReader(r, checkMaxDocument))
+	if err != nil {
+		return false, fmt.Errorf("error reading document: %w", err)
+	}
+
+	re := regexp.MustCompile(`(?i)\s(?:id|name)\s*=\s*["']?` + regexp.QuoteMeta(fragment) + `(?:["'\s/>]|$)`)
+	return re.Match(b), nil
+}
+
+// isHTML reports whether the response is an HTML document
+func isHTML(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html")
+}
+
+// checkURL requests the URL with HEAD, falling back to GET for servers that
+// do not support it. URLs with a fragment are fetched with GET to check the
+// anchor exists in the document.
+func checkURL(client *http.Client, rawURL string) *checkResult {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return &checkResult{Err: err}
+	}
+
+	methods := []string{http.MethodHead, http.MethodGet}
+	fragment := checkedFragment(u)
+	if fragment != "" {
+		methods = methods[1:]
+	}
+
+	var status int
+	for _, method := range methods {
+		req, err := http.NewRequest(method, u.String(), http.NoBody)
+		if err != nil {
+			return &checkResult{Err: fmt.Errorf("error creating request: %w", err)}
+		}
+		req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return &checkResult{Err: err}
+		}
+
+		status = resp.StatusCode
+		if status == http.StatusTooManyRequests {
+			resp.Body.Close()
+			return &checkResult{Status: status, RetryAfter: retryAfter(resp)}
+		}
+
+		if fragment != "" && status == http.StatusOK && isHTML(resp) {
+			found, err := hasAnchor(resp.Body, fragment)
+			resp.Body.Close()
+			if err != nil {
+				return &checkResult{Status: status, Err: err}
+			}
+			if !found {
+				return &checkResult{Status: status, MissingFragment: fragment}
+			}
+			break
+		}
+		resp.Body.Close()
+
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+
+	return &checkResult{Status: status}
+}
+
+// checkItems checks the fetchable items concurrently, storing the results
+// in them
+func checkItems(items []Match) {
+	client := newHTTPClient(checkTimeout)
+	check := func(rawURL string) *checkResult {
+		return checkURL(client, rawURL)
+	}
+	if checkDNSFlag {
+		check = newDNSChecker().check
+	}
+
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < checkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				items[i].Check = checkWithRetry(items[i].Value, check)
+				log.Printf("%s: %s", items[i].Value, items[i].Check)
+			}
+		}()
+	}
+
+	for i := range items {
+		if checkable(&items[i]) {
+			ch <- i
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// brokenOccurrences returns every occurrence in found of the broken items,
+// so each file and line linking to them can be reported
+func brokenOccurrences(items, found []Match) []Match {
+	results := make(map[string]*checkResult)
+	for i := range items {
+		if c := items[i].Check; c != nil && c.broken() {
+			results[items[i].Value] = c
+		}
+	}
+
+	var broken []Match
+	for _, m := range found {
+		if c, ok := results[m.Value]; ok {
+			m.Check = c
+			broken = append(broken, m)
+		}
+	}
+
+	return broken
+}
+
+// runCheck checks the items and outputs the report, found has all the
+// occurrences of the items before removing duplicates
+func runCheck(o *Options, items, found []Match) error {
+	start := time.Now()
+	checkItems(items)
+	stats.checkTime = time.Since(start)
+	stats.countChecks(items)
+
+	var err error
+	switch o.Format {
+	case formatSARIF:
+		err = writeSARIF(brokenOccurrences(items, found))
+	case formatGitHub:
+		err = writeGitHubAnnotations(brokenOccurrences(items, found))
+	default:
+		outputData(o, items)
+	}
+	if err != nil {
+		return err
+	}
+
+	if webhookFlag != "" {
+		if err := notifyCheck(items); err != nil {
+			return err
+		}
+	}
+
+	permanent, transient := stats.permanent, stats.transient
+	if failOnFlag == failOnNone || (failOnFlag == failOnBroken && permanent == 0) ||
+		permanent+transient == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d (%d transient)", errBrokenLinks, permanent+transient, transient)
+}
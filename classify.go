@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// URL content categories
+const (
+	typeWeb     = "web"
+	typeEmail   = "email"
+	typeImage   = "image"
+	typeVideo   = "video"
+	typeAudio   = "audio"
+	typeDoc     = "doc"
+	typeArchive = "archive"
+	typeRepo    = "repo"
+	typeTracker = "tracker"
+)
+
+var urlTypes = []string{
+	typeWeb, typeEmail, typeImage, typeVideo, typeAudio, typeDoc, typeArchive, typeRepo, typeTracker,
+}
+
+var errURLType = errors.New("unknown type")
+
+// typeByExt maps file extensions to their category
+var typeByExt = map[string]string{
+	".png": typeImage, ".jpg": typeImage, ".jpeg": typeImage, ".gif": typeImage,
+	".webp": typeImage, ".svg": typeImage, ".bmp": typeImage, ".ico": typeImage,
+	".avif": typeImage, ".tif": typeImage, ".tiff": typeImage,
+
+	".mp4": typeVideo, ".mkv": typeVideo, ".webm": typeVideo, ".mov": typeVideo,
+	".avi": typeVideo, ".m4v": typeVideo, ".flv": typeVideo, ".m3u8": typeVideo,
+
+	".mp3": typeAudio, ".ogg": typeAudio, ".flac": typeAudio, ".wav": typeAudio,
+	".m4a": typeAudio, ".opus": typeAudio,
+
+	".pdf": typeDoc, ".doc": typeDoc, ".docx": typeDoc, ".odt": typeDoc,
+	".xls": typeDoc, ".xlsx": typeDoc, ".ods": typeDoc, ".ppt": typeDoc,
+	".pptx": typeDoc, ".odp": typeDoc, ".epub": typeDoc, ".rtf": typeDoc,
+	".csv": typeDoc, ".txt": typeDoc,
+
+	".zip": typeArchive, ".tar": typeArchive, ".gz": typeArchive, ".tgz": typeArchive,
+	".bz2": typeArchive, ".xz": typeArchive, ".zst": typeArchive, ".7z": typeArchive,
+	".rar": typeArchive,
+
+	".git": typeRepo,
+}
+
+// typeByHost maps known hosts to their category, subdomains included
+var typeByHost = map[string]string{
+	"youtube.com":     typeVideo,
+	"youtu.be":        typeVideo,
+	"vimeo.com":       typeVideo,
+	"twitch.tv":       typeVideo,
+	"dailymotion.com": typeVideo,
+	"peertube.tv":     typeVideo,
+
+	"soundcloud.com": typeAudio,
+	"bandcamp.com":   typeAudio,
+
+	"github.com":    typeRepo,
+	"gitlab.com":    typeRepo,
+	"codeberg.org":  typeRepo,
+	"bitbucket.org": typeRepo,
+	"git.sr.ht":     typeRepo,
+	"gitea.com":     typeRepo,
+
+	"doubleclick.net":           typeTracker,
+	"google-analytics.com":      typeTracker,
+	"googletagmanager.com":      typeTracker,
+	"googleadservices.com":      typeTracker,
+	"googlesyndication.com":     typeTracker,
+	"analytics.twitter.com":     typeTracker,
+	"pixel.facebook.com":        typeTracker,
+	"bat.bing.com":              typeTracker,
+	"scorecardresearch.com":     typeTracker,
+	"list-manage.com":           typeTracker,
+	"hubspotlinks.com":          typeTracker,
+	"mandrillapp.com":           typeTracker,
+	"sendgrid.net":              typeTracker,
+	"click.convertkit-mail.com": typeTracker,
+}
+
+// validateURLTypes checks the values of the --only-type flag
+func validateURLTypes(types []string) error {
+	for _, t := range types {
+		if !inList(urlTypes, t) {
+			return fmt.Errorf("%w: %q (valid: %s)", errURLType, t, strings.Join(urlTypes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// inList reports whether s is in the list
+func inList(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseURL parses the URL, adding a scheme to scheme-less matches
+func parseURL(s string) (*url.URL, error) {
+	if strings.HasPrefix(strings.ToLower(s), "www.") {
+		s = "http://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %w", err)
+	}
+
+	return u, nil
+}
+
+// hostType returns the category of a known host or one of its subdomains
+func hostType(host string) (string, bool) {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for {
+		if t, ok := typeByHost[host]; ok {
+			return t, true
+		}
+
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return "", false
+		}
+		host = host[i+1:]
+	}
+}
+
+// classify returns the content category of the URL
+func classify(s string) string {
+	if strings.HasPrefix(s, "mailto:") {
+		return typeEmail
+	}
+
+	u, err := parseURL(s)
+	if err != nil {
+		return typeWeb
+	}
+
+	if u.Scheme == "git" {
+		return typeRepo
+	}
+
+	if t, ok := typeByExt[strings.ToLower(path.Ext(u.Path))]; ok {
+		return t
+	}
+
+	if t, ok := hostType(u.Hostname()); ok {
+		return t
+	}
+
+	return typeWeb
+}
+
+// filterByType keeps the items of the given types
+func filterByType(items []Match, types []string) []Match {
+	result := items[:0]
+	for _, m := range items {
+		if inList(types, m.Type) {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
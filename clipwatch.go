@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errClipwatchCommand = errors.New("unknown clipwatch command")
+
+// clipwatchPath returns the path of the URLs copied to the clipboard
+func clipwatchPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "clipwatch.json"), nil
+}
+
+// readClipwatch returns the URLs copied to the clipboard, newest first
+func readClipwatch() ([]string, error) {
+	path, err := clipwatchPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading clipboard history: %w", err)
+	}
+
+	var urls []string
+	if err := json.Unmarshal(b, &urls); err != nil {
+		return nil, fmt.Errorf("error reading clipboard history: %w", err)
+	}
+
+	return urls, nil
+}
+
+// writeClipwatch saves the URLs copied to the clipboard
+func writeClipwatch(urls []string) error {
+	path, err := clipwatchPath()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing clipboard history: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("error writing clipboard history: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing clipboard history: %w", err)
+	}
+
+	return nil
+}
+
+// runClipwatch runs the clipwatch subcommand
+func runClipwatch(o *Options, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runClipwatchWatch(o, args)
+	}
+
+	switch args[0] {
+	case "watch":
+		return runClipwatchWatch(o, args[1:])
+	case "list":
+		return runClipwatchList()
+	case "pick":
+		return runClipwatchPick(o)
+	}
+
+	return fmt.Errorf("%w: %q (valid: watch, list, pick)", errClipwatchCommand, args[0])
+}
+
+// readClipboardBytes returns the clipboard content
+func readClipboardBytes() ([]byte, error) {
+	r, err := readClipboard()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading clipboard: %w", err)
+	}
+
+	return b, nil
+}
+
+// runClipwatchWatch polls the clipboard and keeps the URLs found in what
+// is copied, until it is interrupted
+func runClipwatchWatch(o *Options, args []string) error {
+	fs := flag.NewFlagSet("clipwatch", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Second, "time between clipboard reads")
+	size := fs.Int("size", 200, "number of URLs kept")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("clipwatch: %w", err)
+	}
+	if *interval <= 0 || *size < 1 {
+		return fmt.Errorf("%w: --interval and --size must be greater than 0", errInvalidFlag)
+	}
+
+	urls, err := readClipwatch()
+	if err != nil {
+		return err
+	}
+	recent := &recentURLs{size: *size}
+	for i := len(urls) - 1; i >= 0; i-- {
+		recent.add(urls[i : i+1])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printInfo("watching the clipboard")
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		b, err := readClipboardBytes()
+		if err != nil {
+			log.Print(err)
+		}
+		if err == nil && !bytes.Equal(b, last) {
+			last = b
+			values := extractValues(o, "clipboard", bytes.NewReader(b))
+			var fresh []string
+			for _, v := range values {
+				if indexOf(recent.list(), v) < 0 && indexOf(fresh, v) < 0 {
+					fresh = append(fresh, v)
+				}
+			}
+			if n := recent.add(values); n > 0 {
+				log.Printf("clipwatch: %d new urls", n)
+				if err := writeClipwatch(recent.list()); err != nil {
+					return err
+				}
+				if err := notifyFound(fresh); err != nil {
+					log.Print(err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runClipwatchList prints the URLs copied to the clipboard, newest first
+func runClipwatchList() error {
+	urls, err := readClipwatch()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range urls {
+		fmt.Println(u)
+	}
+
+	return nil
+}
+
+// runClipwatchPick shows the URLs copied to the clipboard in the menu and
+// runs the action on the selected ones
+func runClipwatchPick(o *Options) error {
+	urls, err := readClipwatch()
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		printInfo("clipboard history is empty")
+		return nil
+	}
+
+	items := make([]Match, 0, len(urls))
+	for _, u := range urls {
+		items = append(items, Match{Value: u, Source: "clipboard", Type: classify(u)})
+	}
+
+	menu.addArgs()
+	menu.prompt("Clipboard>")
+	if wantsMulti(selectedAction()) {
+		menu.Arguments = append(menu.Arguments, menu.MultiArgs...)
+	}
+
+	selected, ok := selectURL(o, items)
+	if !ok {
+		return nil
+	}
+
+	handleURLAction(o, selected)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+)
+
+// compression formats, detected by their magic bytes
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns a reader that decompresses r if it is compressed with
+// gzip, bzip2, xz or zstd, otherwise r is returned as is
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(magicXz))
+
+	switch {
+	case bytes.HasPrefix(head, magicGzip):
+		log.Print("input compressed with gzip")
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing gzip: %w", err)
+		}
+		return zr, nil
+	case bytes.HasPrefix(head, magicBzip2):
+		log.Print("input compressed with bzip2")
+		return bzip2.NewReader(br), nil
+	case bytes.HasPrefix(head, magicXz):
+		return decompressCmd(br, "xz")
+	case bytes.HasPrefix(head, magicZstd):
+		return decompressCmd(br, "zstd")
+	}
+
+	return br, nil
+}
+
+// decompressCmd decompresses r using an external command
+func decompressCmd(r io.Reader, name string) (io.Reader, error) {
+	log.Printf("input compressed with %s", name)
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("error decompressing %s: %w: %s", name, errMissingCmd, name)
+	}
+
+	cmd := exec.Command(name, "--decompress", "--stdout")
+	cmd.Stdin = r
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing %s: %w", name, err)
+	}
+
+	return bytes.NewReader(output), nil
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var errConfig = errors.New("invalid config")
+
+// ActionConfig is a user-defined action
+type ActionConfig struct {
+	Name string `json:"name"`
+	// Command is the command template, {url} is replaced with the selected
+	// item
+	Command string `json:"command"`
+	// Confirm asks before running the action
+	Confirm bool `json:"confirm"`
+	// Multi allows running the action on several selected items
+	Multi bool `json:"multi"`
+}
+
+// Config holds the settings read from the config file, the flags given
+// take precedence over them
+type Config struct {
+	// Menu is the menu command, with its arguments
+	Menu     string `json:"menu"`
+	MenuArgs string `json:"menu_args"`
+	Opener   string `json:"opener"`
+	Player   string `json:"player"`
+	Prompt   string `json:"prompt"`
+	// OpenWith maps URL schemes to the command, or app on macOS, that opens
+	// them
+	OpenWith map[string]string `json:"open_with"`
+	// Regex is used instead of the built-in finders, like -E
+	Regex   string         `json:"regex"`
+	Limit   int            `json:"limit"`
+	Actions []ActionConfig `json:"actions"`
+	// Hooks is the path of the Starlark hooks script
+	Hooks string `json:"hooks"`
+	// Unwrap are the redirectors unwrapped by --unwrap, besides the
+	// built-in ones
+	Unwrap []UnwrapRule `json:"unwrap"`
+	// DefaultScheme is added to the 'www.' links, like --default-scheme
+	DefaultScheme string `json:"default_scheme"`
+	// History saves the selection to the history, like --history
+	History bool `json:"history"`
+	// Modes are named bundles of flags, applied with --mode
+	Modes map[string]map[string]any `json:"modes"`
+}
+
+// origins of the settings
+const (
+	originDefault = "default"
+	originFile    = "file"
+	originEnv     = "env"
+	originFlag    = "flag"
+)
+
+// setting is a flag that can also be set in the config file and with an
+// environment variable
+type setting struct {
+	// file returns the value in the config file, empty when not set
+	file func(c *Config) string
+	// set applies the value to the options or the flags
+	set func(o *Options, s string) error
+	// value returns the effective value
+	value func(o *Options) string
+	key   string
+	env   string
+	flags []string
+}
+
+var errSetting = errors.New("invalid setting")
+
+// settings are the flags that can be set in the config file and the
+// environment
+var settings = []setting{
+	{
+		key:  "menu",
+		env:  "GOURL_MENU",
+		file: func(c *Config) string { return c.Menu },
+		set: func(_ *Options, s string) error {
+			if err := checkCommand("menu", s); err != nil {
+				return err
+			}
+			platform.Menu = menuFromCommand(s)
+			menu = platform.Menu
+			return nil
+		},
+		value: func(_ *Options) string { return strings.Join(append([]string{menu.Command}, menu.Arguments...), " ") },
+	},
+	{
+		key:   "menu_args",
+		env:   "GOURL_MENU_ARGS",
+		flags: []string{"a", "menu-args"},
+		file:  func(c *Config) string { return c.MenuArgs },
+		set: func(_ *Options, s string) error {
+			if err := checkCommand("menu_args", s); err != nil {
+				return err
+			}
+			menuArgsFlag = s
+			return nil
+		},
+		value: func(_ *Options) string { return menuArgsFlag },
+	},
+	{
+		key:   "opener",
+		env:   "GOURL_OPEN_CMD",
+		flags: []string{"opener"},
+		file:  func(c *Config) string { return c.Opener },
+		set:   func(_ *Options, s string) error { xdgOpen = s; return nil },
+		value: func(_ *Options) string { return xdgOpen },
+	},
+	{
+		key:   "player",
+		env:   "GOURL_PLAYER",
+		flags: []string{"player"},
+		file:  func(c *Config) string { return c.Player },
+		set:   func(_ *Options, s string) error { playerFlag = s; return nil },
+		value: func(_ *Options) string { return playerFlag },
+	},
+	{
+		key:   "hooks",
+		env:   "GOURL_HOOKS",
+		flags: []string{"hooks"},
+		file:  func(c *Config) string { return c.Hooks },
+		set:   func(_ *Options, s string) error { hooksFlag = s; return nil },
+		value: func(_ *Options) string { return hooksFlag },
+	},
+	{
+		key:   "default_scheme",
+		env:   "GOURL_DEFAULT_SCHEME",
+		flags: []string{"default-scheme"},
+		file:  func(c *Config) string { return c.DefaultScheme },
+		set: func(o *Options, s string) error {
+			if err := validateDefaultScheme(s); err != nil {
+				return fmt.Errorf("%w: default_scheme: %w", errSetting, err)
+			}
+			o.DefaultScheme = s
+			return nil
+		},
+		value: func(o *Options) string { return o.DefaultScheme },
+	},
+	{
+		key:   "prompt",
+		env:   "GOURL_PROMPT",
+		flags: []string{"prompt"},
+		file:  func(c *Config) string { return c.Prompt },
+		set:   func(_ *Options, s string) error { promptFlag = s; return nil },
+		value: func(_ *Options) string { return promptFlag },
+	},
+	{
+		key:   "regex",
+		env:   "GOURL_REGEX",
+		flags: []string{"E", "regex"},
+		file:  func(c *Config) string { return c.Regex },
+		set: func(o *Options, s string) error {
+			if _, err := regexp.Compile(s); err != nil {
+				return fmt.Errorf("%w: regex: %w", errSetting, err)
+			}
+			o.Regex = s
+			return nil
+		},
+		value: func(o *Options) string { return o.Regex },
+	},
+	{
+		key:   "history",
+		env:   "GOURL_HISTORY",
+		flags: []string{"history"},
+		file: func(c *Config) string {
+			if !c.History {
+				return ""
+			}
+			return "true"
+		},
+		set: func(_ *Options, s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("%w: history: %q is not true or false", errSetting, s)
+			}
+			historyFlag = b
+			return nil
+		},
+		value: func(_ *Options) string { return strconv.FormatBool(historyFlag) },
+	},
+	{
+		key:   "limit",
+		env:   "GOURL_LIMIT",
+		flags: []string{"l", "limit"},
+		file: func(c *Config) string {
+			if c.Limit == 0 {
+				return ""
+			}
+			return strconv.Itoa(c.Limit)
+		},
+		set: func(o *Options, s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%w: limit: %q is not a positive number", errSetting, s)
+			}
+			o.Limit = n
+			return nil
+		},
+		value: func(o *Options) string { return strconv.Itoa(o.Limit) },
+	},
+}
+
+// settingOrigins holds where the value of each setting comes from
+var settingOrigins = make(map[string]string)
+
+// applySettings applies the settings that were not given as flags, from
+// the environment or else the config file
+func applySettings(c *Config, o *Options) error {
+	for _, s := range settings {
+		switch {
+		case settingOrigins[s.key] == originMode:
+		case len(s.flags) > 0 && flagSet(s.flags...):
+			settingOrigins[s.key] = originFlag
+		case os.Getenv(s.env) != "":
+			if err := s.set(o, os.Getenv(s.env)); err != nil {
+				return fmt.Errorf("%s: %w", s.env, err)
+			}
+			settingOrigins[s.key] = originEnv
+		case s.file(c) != "":
+			if err := s.set(o, s.file(c)); err != nil {
+				return fmt.Errorf("%w: %w", errConfig, err)
+			}
+			settingOrigins[s.key] = originFile
+		default:
+			settingOrigins[s.key] = originDefault
+		}
+	}
+
+	return nil
+}
+
+// config is the loaded config file
+var config Config
+
+// configErr is the error loading the config, only kept for the config
+// subcommand
+var configErr error
+
+// configPath returns the path of the config file
+func configPath() (string, error) {
+	if configFlag != "" {
+		return configFlag, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting config dir: %w", err)
+	}
+
+	return filepath.Join(dir, appName, "config.json"), nil
+}
+
+// loadConfig reads the config file, a missing file is an empty config
+// unless the path was given with --config
+func loadConfig() (Config, error) {
+	var c Config
+	path, err := configPath()
+	if err != nil {
+		return c, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && configFlag == "" {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("error reading config: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%w: %s: %w", errConfig, path, err)
+	}
+
+	return c, nil
+}
+
+// registerConfigActions adds the user-defined actions to the registry
+func registerConfigActions(c *Config) error {
+	for _, a := range c.Actions {
+		if a.Name == "" || strings.TrimSpace(a.Command) == "" {
+			return fmt.Errorf("%w: actions need a name and a command", errConfig)
+		}
+
+		command, err := splitArgs(a.Command)
+		if err != nil {
+			return fmt.Errorf("%w: action %q: %w", errConfig, a.Name, err)
+		}
+
+		registerAction(&ExecAction{
+			ActionName: a.Name,
+			Command:    command,
+			Confirm:    a.Confirm,
+			multi:      a.Multi,
+		})
+		customActions = append(customActions, a.Name)
+	}
+
+	return nil
+}
+
+// jsonKeys returns the JSON keys of the struct fields
+func jsonKeys(v any) []string {
+	t := reflect.TypeOf(v)
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		keys = append(keys, name)
+	}
+
+	return keys
+}
+
+// unknownKeys returns the keys of the JSON object not in known, sorted
+func unknownKeys(obj map[string]json.RawMessage, known []string) []string {
+	var unknown []string
+	for k := range obj {
+		if !inList(known, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+// checkConfig returns the problems found in the config file: unknown keys,
+// invalid values, bad regexes and commands not found in PATH
+func checkConfig(b []byte) []string {
+	var problems []string
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return []string{err.Error()}
+	}
+	for _, k := range unknownKeys(obj, jsonKeys(Config{})) {
+		problems = append(problems, fmt.Sprintf("unknown key %q", k))
+	}
+
+	var actionObjs []map[string]json.RawMessage
+	if raw, ok := obj["actions"]; ok && json.Unmarshal(raw, &actionObjs) == nil {
+		for i, a := range actionObjs {
+			for _, k := range unknownKeys(a, jsonKeys(ActionConfig{})) {
+				problems = append(problems, fmt.Sprintf("actions[%d]: unknown key %q", i, k))
+			}
+		}
+	}
+
+	var unwrapObjs []map[string]json.RawMessage
+	if raw, ok := obj["unwrap"]; ok && json.Unmarshal(raw, &unwrapObjs) == nil {
+		for i, r := range unwrapObjs {
+			for _, k := range unknownKeys(r, jsonKeys(UnwrapRule{})) {
+				problems = append(problems, fmt.Sprintf("unwrap[%d]: unknown key %q", i, k))
+			}
+		}
+	}
+
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return append(problems, err.Error())
+	}
+
+	if c.Regex != "" {
+		if _, err := regexp.Compile(c.Regex); err != nil {
+			problems = append(problems, fmt.Sprintf("regex: %s", err))
+		}
+	}
+	if c.Limit < 0 {
+		problems = append(problems, "limit: must not be negative")
+	}
+	for _, name := range modeNames(&c) {
+		for _, p := range checkMode(c.Modes[name]) {
+			problems = append(problems, fmt.Sprintf("modes.%s: %s", name, p))
+		}
+	}
+	for i := range c.Unwrap {
+		if err := c.Unwrap[i].validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("unwrap[%d]: %s", i, strings.TrimPrefix(err.Error(), errConfig.Error()+": ")))
+		}
+	}
+
+	if c.Hooks != "" {
+		if _, err := loadHooks(c.Hooks); err != nil {
+			problems = append(problems, fmt.Sprintf("hooks: %s", strings.TrimPrefix(err.Error(), errHook.Error()+": ")))
+		}
+	}
+
+	commands := map[string]string{"menu": c.Menu, "player": c.Player}
+	if c.Opener != openerPortal {
+		commands["opener"] = c.Opener
+	}
+
+	// on macOS the openers by scheme are app names
+	if platform.Name != macos.Name {
+		for scheme, with := range c.OpenWith {
+			commands["open_with."+scheme] = with
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, a := range c.Actions {
+		switch {
+		case a.Name == "" || strings.TrimSpace(a.Command) == "":
+			problems = append(problems, fmt.Sprintf("actions[%d]: needs a name and a command", i))
+			continue
+		case seen[a.Name]:
+			problems = append(problems, fmt.Sprintf("actions[%d]: duplicated name %q", i, a.Name))
+		}
+		seen[a.Name] = true
+		commands[fmt.Sprintf("actions[%d] (%s)", i, a.Name)] = a.Command
+	}
+
+	keys := make([]string, 0, len(commands))
+	for k := range commands {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields, err := splitArgs(commands[k])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", k, err))
+			continue
+		}
+		if len(fields) == 0 || k == "menu" && menus[fields[0]].Run != nil {
+			continue
+		}
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: command not found: %s", k, fields[0]))
+		}
+	}
+
+	return problems
+}
+
+// runConfig runs the config subcommand
+func runConfig(o *Options, args []string) error {
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch args[0] {
+	case "check":
+		return runConfigCheck()
+	case "show":
+		return runConfigShow(o)
+	}
+
+	return fmt.Errorf("%w: unknown config command %q (valid: check, show)", errConfig, args[0])
+}
+
+// runConfigCheck validates the config file
+func runConfigCheck() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("%s: not found, using the defaults\n", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+
+	problems := checkConfig(b)
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", path, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %d problem(s) found", errConfig, len(problems))
+	}
+
+	fmt.Printf("%s: ok\n", path)
+	return nil
+}
+
+// runConfigShow prints the effective settings and where they come from
+func runConfigShow(o *Options) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		path += " (not found)"
+	}
+	if configErr != nil {
+		printInfo(configErr.Error())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "config\t%s\n", path)
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.key, s.value(o), settingOrigins[s.key])
+	}
+	for _, a := range config.Actions {
+		var opts []string
+		if a.Confirm {
+			opts = append(opts, "confirm")
+		}
+		if a.Multi {
+			opts = append(opts, "multi")
+		}
+		line := fmt.Sprintf("action %s\t%s", a.Name, a.Command)
+		if len(opts) > 0 {
+			line += "\t" + strings.Join(opts, ", ")
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+
+	return nil
+}
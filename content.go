@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// input content types
+const (
+	inputAuto   = "auto"
+	inputText   = "text"
+	inputHTML   = "html"
+	inputJSON   = "json"
+	inputPDF    = "pdf"
+	inputMbox   = "mbox"
+	inputBinary = "binary"
+)
+
+var inputTypes = []string{inputAuto, inputText, inputHTML, inputJSON, inputPDF, inputMbox, inputBinary}
+
+var errInputType = errors.New("unknown input type")
+
+// pdfMagic is the signature of a PDF document
+var pdfMagic = []byte("%PDF-")
+
+// sniffLen is the number of bytes used to detect the content type
+const sniffLen = 512
+
+// validateInputType checks the value of the --input flag
+func validateInputType(s string) error {
+	for _, t := range inputTypes {
+		if s == t {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q (valid: %s)", errInputType, s, strings.Join(inputTypes, ", "))
+}
+
+// mailHeaders are headers commonly found at the start of an email
+var mailHeaders = []string{
+	"Return-Path:", "Received:", "Delivered-To:", "MIME-Version:", "Message-ID:",
+}
+
+// sniffInput returns the content type of the data
+func sniffInput(b []byte) string {
+	trimmed := bytes.TrimLeft(b, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(b, pdfMagic):
+		return inputPDF
+	case bytes.HasPrefix(b, []byte("From ")):
+		return inputMbox
+	case len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '['):
+		return inputJSON
+	}
+
+	for _, h := range mailHeaders {
+		if bytes.HasPrefix(b, []byte(h)) {
+			return inputMbox
+		}
+	}
+
+	if strings.HasPrefix(http.DetectContentType(b), "text/html") {
+		return inputHTML
+	}
+
+	return inputText
+}
+
+// convertInput returns a reader with the text in r, processed according to
+// its content type
+func (o *Options) convertInput(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	kind := o.Input
+
+	// PDF and binary data are not text, leave them as they are
+	head, _ := br.Peek(sniffLen)
+	if kind != inputBinary && kind != inputPDF && !bytes.HasPrefix(head, pdfMagic) {
+		t, err := transcode(br, o.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(t)
+	}
+
+	if kind == inputAuto {
+		head, _ := br.Peek(sniffLen)
+		kind = sniffInput(head)
+	}
+
+	log.Printf("input type: %s", kind)
+	if kind == inputText {
+		return br, nil
+	}
+
+	b, err := io.ReadAll(br)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	switch kind {
+	case inputHTML:
+		b = htmlToText(b)
+	case inputJSON:
+		b = jsonToText(b)
+	case inputPDF:
+		b, err = pdfToText(b)
+	case inputMbox:
+		b = decodeQuotedPrintable(b)
+	case inputBinary:
+		b = extractStrings(b, o.MinRun)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// htmlToText puts every tag in its own line and decodes the HTML entities,
+// so '&amp;' in links does not end up in the URLs found
+func htmlToText(b []byte) []byte {
+	s := strings.ReplaceAll(string(b), ">", ">\n")
+	return []byte(html.UnescapeString(s))
+}
+
+// jsonToText returns the strings found in the JSON values, one per line,
+// with their escape sequences decoded. Invalid JSON is returned as is.
+func jsonToText(b []byte) []byte {
+	var buf bytes.Buffer
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return buf.Bytes()
+		}
+		if err != nil {
+			log.Printf("invalid json, using plain text: %s", err)
+			return b
+		}
+
+		if s, ok := t.(string); ok {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// pdfToText extracts the text from a PDF document with pdftotext
+func pdfToText(b []byte) ([]byte, error) {
+	const pdftotext = "pdftotext"
+	if _, err := exec.LookPath(pdftotext); err != nil {
+		return nil, fmt.Errorf("error reading PDF: %w: %s", errMissingCmd, pdftotext)
+	}
+
+	cmd := exec.Command(pdftotext, "-q", "-", "-")
+	cmd.Stdin = bytes.NewReader(b)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF: %w", err)
+	}
+
+	return output, nil
+}
+
+var (
+	qpSoftBreak = regexp.MustCompile(`=\r?\n`)
+	qpEscape    = regexp.MustCompile(`=[0-9A-F]{2}`)
+)
+
+// decodeQuotedPrintable joins the soft line breaks and decodes the escaped
+// bytes of quoted-printable mail bodies, leaving anything else untouched
+func decodeQuotedPrintable(b []byte) []byte {
+	b = qpSoftBreak.ReplaceAll(b, nil)
+	return qpEscape.ReplaceAllFunc(b, func(m []byte) []byte {
+		c, err := strconv.ParseUint(string(m[1:]), 16, 8)
+		if err != nil {
+			return m
+		}
+		return []byte{byte(c)}
+	})
+}
+
+// isPrintable reports whether c is a printable ASCII character or a tab
+func isPrintable(c byte) bool {
+	return c == '\t' || (c >= 0x20 && c < 0x7f)
+}
+
+// extractStrings returns the runs of at least minRun printable characters
+// found in b, one per line, like strings(1)
+func extractStrings(b []byte, minRun int) []byte {
+	var buf bytes.Buffer
+	start := -1
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && isPrintable(b[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 && i-start >= minRun {
+			buf.Write(b[start:i])
+			buf.WriteByte('\n')
+		}
+		start = -1
+	}
+
+	return buf.Bytes()
+}
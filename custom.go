@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regexFlagChars are the flags accepted by --regex-flags
+const regexFlagChars = "imsU"
+
+// customPattern returns the -E pattern with --fixed-string and
+// --regex-flags applied
+func (o *Options) customPattern(regex string) string {
+	if o.Fixed {
+		regex = regexp.QuoteMeta(regex)
+	}
+
+	var set string
+	for _, c := range o.RegexFlags {
+		if strings.ContainsRune(regexFlagChars, c) && !strings.ContainsRune(set, c) {
+			set += string(c)
+		}
+	}
+	if set != "" {
+		regex = "(?" + set + ")" + regex
+	}
+
+	return regex
+}
+
+// customItems returns the matches of the custom regex in the sources
+func (o *Options) customItems(sources []source, regex string, onItems func([]Match)) ([]Match, error) {
+	var items []Match
+	switch {
+	case o.Engine == enginePCRE:
+		var err error
+		if items, err = o.findWithPCRE(sources, regex); err != nil {
+			return nil, err
+		}
+	case o.Window > 0 || o.Multiline:
+		items = o.findWithContext(sources, regex)
+	default:
+		return o.getURLsFrom(sources, onItems, o.customMatcher(regex))
+	}
+
+	if len(items) == 0 {
+		return nil, errNoURLFound
+	}
+
+	return items, nil
+}
+
+// validateCustomRegex checks the --regex-flags and the -E pattern, so an
+// invalid pattern is reported instead of panicking
+func (o *Options) validateCustomRegex() error {
+	regex := o.Regex
+	for _, c := range o.RegexFlags {
+		if c != ',' && c != ' ' && !strings.ContainsRune(regexFlagChars, c) {
+			return fmt.Errorf("%w: --regex-flags: unknown flag %q (valid: i, m, s, U)", errInvalidFlag, c)
+		}
+	}
+
+	if regex == "" {
+		if o.Fixed || o.RegexFlags != "" {
+			return fmt.Errorf("%w: --fixed-string and --regex-flags require -E", errInvalidFlag)
+		}
+		return nil
+	}
+
+	if o.Engine == enginePCRE {
+		return checkPCRE(o.customPattern(regex))
+	}
+
+	if _, err := regexp.Compile(o.customPattern(regex)); err != nil {
+		return fmt.Errorf("%w: -E: %w (use --fixed-string to match it literally)", errInvalidFlag, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemon protocol commands, sent in the first line of a connection
+const (
+	cmdSend = "SEND"
+	cmdList = "LIST"
+)
+
+const daemonTimeout = 5 * time.Second
+
+// daemonConnTimeout is how long a client has to send its input and read
+// the reply, so stuck clients don't hold their connections forever
+const daemonConnTimeout = time.Minute
+
+var (
+	errDaemonRunning = errors.New("daemon already running")
+	errDaemonCommand = errors.New("unknown daemon command")
+	errNotSocket     = errors.New("not a socket")
+)
+
+// socketPath returns the path of the daemon socket
+func socketPath() string {
+	if socketFlag != "" {
+		return socketFlag
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, appName+".sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d.sock", appName, os.Getuid()))
+}
+
+// recentURLs is a rolling set of the most recent URLs, newest first
+type recentURLs struct {
+	items []string
+	size  int
+	mu    sync.Mutex
+}
+
+// add moves the URLs to the front of the set, dropping the oldest ones
+// when it is full, and returns how many were new
+func (r *recentURLs) add(urls []string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, u := range urls {
+		i := indexOf(r.items, u)
+		if i >= 0 {
+			r.items = append(r.items[:i], r.items[i+1:]...)
+		} else {
+			n++
+		}
+		r.items = append([]string{u}, r.items...)
+	}
+
+	if len(r.items) > r.size {
+		r.items = r.items[:r.size]
+	}
+
+	return n
+}
+
+// list returns a copy of the URLs, newest first
+func (r *recentURLs) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.items...)
+}
+
+// indexOf returns the index of s in the list, or -1
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// handleConn serves a daemon client
+func handleConn(o *Options, conn net.Conn, recent *recentURLs) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(daemonConnTimeout)); err != nil {
+		log.Printf("daemon: %s", err)
+		return
+	}
+
+	br := bufio.NewReader(conn)
+	cmd, err := br.ReadString('\n')
+	if err != nil {
+		// probes from clients checking if the daemon is running
+		if !errors.Is(err, io.EOF) {
+			log.Printf("daemon: error reading command: %s", err)
+		}
+		return
+	}
+
+	switch strings.TrimSpace(cmd) {
+	case cmdSend:
+		urls := extractValues(o, "daemon", br)
+		n := recent.add(urls)
+		log.Printf("daemon: received %d urls, %d new", len(urls), n)
+		fmt.Fprintln(conn, n)
+	case cmdList:
+		for _, u := range recent.list() {
+			fmt.Fprintln(conn, u)
+		}
+	default:
+		log.Printf("daemon: %s: %q", errDaemonCommand, cmd)
+	}
+}
+
+// extractValues returns the items found in the text sent by a client or
+// copied to the clipboard, in the order they appear, so the last one ends
+// up first in the set
+func extractValues(o *Options, name string, r io.Reader) []string {
+	sources, err := o.prepareInput(name, r)
+	if err != nil {
+		log.Printf("%s: %s", name, err)
+		return nil
+	}
+
+	items, err := o.extractItems(sources, nil)
+	if err != nil {
+		return nil
+	}
+
+	urls := make([]string, 0, len(items))
+	for _, m := range uniqueItems(items) {
+		urls = append(urls, m.Value)
+	}
+
+	return urls
+}
+
+// removeStaleSocket removes the socket left by a daemon that did not exit
+// cleanly, any other file at the path is left alone
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%s: %w", path, errNotSocket)
+	}
+
+	return os.Remove(path)
+}
+
+// runDaemon listens on the socket and keeps the URLs pushed by clients
+func runDaemon(o *Options) error {
+	path := socketPath()
+	if conn, err := net.DialTimeout("unix", path, daemonTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %s", errDaemonRunning, path)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return fmt.Errorf("error starting daemon: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("error starting daemon: %w", err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	printInfo("daemon listening on " + path)
+	recent := &recentURLs{size: daemonSizeFlag}
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error accepting connection: %w", err)
+		}
+
+		go handleConn(o, conn, recent)
+	}
+}
+
+// dialDaemon connects to the daemon and sends the command
+func dialDaemon(cmd string) (*net.UnixConn, error) {
+	conn, err := net.DialTimeout("unix", socketPath(), daemonTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to daemon: %w", err)
+	}
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to daemon: %w", net.UnknownNetworkError("unix"))
+	}
+
+	if _, err := fmt.Fprintln(uc, cmd); err != nil {
+		uc.Close()
+		return nil, fmt.Errorf("error sending to daemon: %w", err)
+	}
+
+	return uc, nil
+}
+
+// sendToDaemon pushes the content of the input sources to the daemon
+func sendToDaemon(sources []source) error {
+	conn, err := dialDaemon(cmdSend)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, s := range sources {
+		if _, err := io.Copy(conn, s.r); err != nil {
+			return fmt.Errorf("error sending to daemon: %w", err)
+		}
+		if _, err := io.WriteString(conn, "\n"); err != nil {
+			return fmt.Errorf("error sending to daemon: %w", err)
+		}
+	}
+
+	if err := conn.CloseWrite(); err != nil {
+		return fmt.Errorf("error sending to daemon: %w", err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return fmt.Errorf("error reading daemon reply: %w", err)
+	}
+
+	n, _ := strconv.Atoi(strings.TrimSpace(string(reply)))
+	log.Printf("sent to daemon, %d new urls", n)
+	return nil
+}
+
+// readDaemon returns a reader with the recent URLs kept by the daemon
+func readDaemon() (io.Reader, error) {
+	conn, err := dialDaemon(cmdList)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from daemon: %w", err)
+	}
+
+	return strings.NewReader(string(b)), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	sock := tempSocket(t)
+	dir := filepath.Dir(sock)
+
+	if err := removeStaleSocket(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing path: %s", err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeStaleSocket(file); !errors.Is(err, errNotSocket) {
+		t.Errorf("regular file: got %v, want %v", err, errNotSocket)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file removed: %s", err)
+	}
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %s", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if err := removeStaleSocket(sock); err != nil {
+		t.Errorf("stale socket: %s", err)
+	}
+	if _, err := os.Lstat(sock); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale socket not removed: %v", err)
+	}
+}
+
+// tempSocket returns the path of a socket in a new directory, t.TempDir
+// can be longer than the path of a unix socket allows
+func tempSocket(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "gourl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "sock")
+}
+
+func TestHandleConn(t *testing.T) {
+	l, err := net.Listen("unix", tempSocket(t))
+	if err != nil {
+		t.Skipf("unix sockets not supported: %s", err)
+	}
+	defer l.Close()
+
+	recent := &recentURLs{size: 10}
+	request := func(cmd, input string) string {
+		t.Helper()
+		conn, err := net.Dial("unix", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		server, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go handleConn(newOptions(), server, recent)
+
+		fmt.Fprintf(conn, "%s\n%s", cmd, input)
+		if err := conn.(*net.UnixConn).CloseWrite(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	if got := request(cmdSend, "see https://a.example and https://b.example"); got != "2\n" {
+		t.Errorf("SEND: got %q, want 2 new", got)
+	}
+	if got := request(cmdSend, "https://a.example"); got != "0\n" {
+		t.Errorf("SEND again: got %q, want 0 new", got)
+	}
+	got := strings.Fields(request(cmdList, ""))
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LIST: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var errUnsafeURL = errors.New("refusing to run pseudo-URL")
+
+// unsafeSchemes run code or carry content instead of pointing somewhere,
+// they are never passed to the opener or other commands
+var unsafeSchemes = []string{"javascript", "vbscript", "data"}
+
+// checkSafeURL returns an error for the data:, javascript: and vbscript:
+// pseudo-URLs. Browsers ignore the spaces and control characters before
+// the scheme, so they are ignored here too.
+func checkSafeURL(s string) error {
+	s = strings.TrimLeftFunc(s, func(r rune) bool { return r <= ' ' })
+	scheme, _, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil
+	}
+	scheme = strings.ToLower(strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(scheme))
+	if inList(unsafeSchemes, scheme) {
+		return fmt.Errorf("%w: %s:", errUnsafeURL, scheme)
+	}
+
+	return nil
+}
+
+// dataURI matches the data URIs, with the media type, the base64 marker and
+// the data as groups
+var dataURI = regexp.MustCompile(`(?i)\bdata:([a-z0-9!#$&^_.+-]+/[a-z0-9!#$&^_.+-]+)?(?:;[a-z0-9_.+-]+=[^;,\s"'<>]*)*(;base64)?,([A-Za-z0-9+/=%_.~!*'();:@&$-]*)`)
+
+// decodeDataURI returns the media type and the content of the data URI
+// matched by dataURI
+func decodeDataURI(m []string) (string, []byte, error) {
+	mediaType := strings.ToLower(m[1])
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+
+	if m[2] == "" {
+		s, err := url.PathUnescape(m[3])
+		if err != nil {
+			return "", nil, fmt.Errorf("error decoding data URI: %w", err)
+		}
+		return mediaType, []byte(s), nil
+	}
+
+	data := strings.TrimRight(m[3], "=")
+	b, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(data)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error decoding data URI: %w", err)
+	}
+
+	return mediaType, b, nil
+}
+
+// dataURIExt returns the file extension of the media type
+func dataURIExt(mediaType string) string {
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "text/plain":
+		return ".txt"
+	}
+
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ".bin"
+}
+
+// extractDataURIs writes the content of the data URIs found in the input
+// to files in dir, named by their hash so each one is written once, and
+// prints their paths
+func extractDataURIs(o *Options, sources []source, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating data URI dir: %w", err)
+	}
+
+	written := make(map[string]bool)
+	for _, line := range o.processInputData(sources) {
+		for _, m := range dataURI.FindAllStringSubmatch(line.text, -1) {
+			mediaType, b, err := decodeDataURI(m)
+			if err != nil {
+				log.Printf("%s:%d: %s", line.source, line.num, err)
+				continue
+			}
+
+			sum := sha256.Sum256(b)
+			path := filepath.Join(dir, hex.EncodeToString(sum[:6])+dataURIExt(mediaType))
+			if written[path] {
+				continue
+			}
+			written[path] = true
+
+			if err := os.WriteFile(path, b, 0o644); err != nil {
+				return fmt.Errorf("error writing data URI: %w", err)
+			}
+			fmt.Println(path)
+		}
+	}
+
+	if len(written) == 0 {
+		return errNoURLFound
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var errDiffArgs = errors.New("diff needs two inputs")
+
+// URLDiff is the JSON output of the diff subcommand
+type URLDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// extractFile returns the unique items found in the file, "-" reads from
+// STDIN
+func extractFile(o *Options, path string) ([]Match, error) {
+	r, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := path
+	if path == "-" {
+		name = "stdin"
+	}
+	sources, err := o.prepareInput(name, r)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := o.extractItems(sources, nil)
+	if errors.Is(err, errNoURLFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sortByInput(items, sources)
+
+	return uniqueItems(items), nil
+}
+
+// presence returns the values found in the groups of items, in the order
+// they appear, and in which groups each one was found
+func presence(groups ...[]Match) ([]string, map[string][]bool) {
+	var values []string
+	found := make(map[string][]bool)
+	for g, items := range groups {
+		for _, m := range items {
+			if _, ok := found[m.Value]; !ok {
+				found[m.Value] = make([]bool, len(groups))
+				values = append(values, m.Value)
+			}
+			found[m.Value][g] = true
+		}
+	}
+
+	return values, found
+}
+
+// diffItems returns the values only found in b, and the ones only found in
+// a, in the order they appear
+func diffItems(a, b []Match) URLDiff {
+	d := URLDiff{Added: []string{}, Removed: []string{}}
+	values, found := presence(a, b)
+	for _, v := range values {
+		switch in := found[v]; {
+		case in[0] && !in[1]:
+			d.Removed = append(d.Removed, v)
+		case !in[0] && in[1]:
+			d.Added = append(d.Added, v)
+		}
+	}
+
+	return d
+}
+
+// runSetOp prints the URLs found in all the files with --intersect, or in
+// any of them with --union, with a column marking the files each one is
+// found in
+func runSetOp(o *Options, paths []string) error {
+	if len(paths) < 2 {
+		return fmt.Errorf("%w: --intersect and --union need two or more files", errInvalidFlag)
+	}
+
+	groups := make([][]Match, 0, len(paths))
+	for _, path := range paths {
+		items, err := extractFile(o, path)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, items)
+	}
+
+	values, found := presence(groups...)
+	var rows [][]string
+	for _, v := range values {
+		row := []string{o.outputValue(v)}
+		all := true
+		for _, in := range found[v] {
+			all = all && in
+			row = append(row, presenceMark(in))
+		}
+		if intersectFlag && !all {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return errNoURLFound
+	}
+
+	switch o.Format {
+	case formatCSV, formatTSV:
+		sep := ','
+		if o.Format == formatTSV {
+			sep = '\t'
+		}
+		return writeRows(append([]string{"url"}, paths...), rows, sep)
+	}
+
+	for _, row := range rows {
+		fmt.Println(strings.Join(row[1:], "") + "\t" + row[0])
+	}
+
+	return nil
+}
+
+// presenceMark returns the mark of a file in the presence column, "x"
+// when the URL is found in it
+func presenceMark(in bool) string {
+	if in {
+		return "x"
+	}
+
+	return "."
+}
+
+// runDiff reports the URLs added and removed between two inputs
+func runDiff(o *Options, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output the diff as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s diff [--json] old new\n\nOptions:\n", appName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+	if fs.NArg() != 2 {
+		return errDiffArgs
+	}
+
+	old, err := extractFile(o, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cur, err := extractFile(o, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := diffItems(old, cur)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			return fmt.Errorf("error writing diff: %w", err)
+		}
+		return nil
+	}
+
+	for _, u := range d.Removed {
+		fmt.Println("- " + o.outputValue(u))
+	}
+	for _, u := range d.Added {
+		fmt.Println("+ " + o.outputValue(u))
+	}
+
+	return nil
+}
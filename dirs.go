@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// cacheDir returns the cache directory of the app, creating it if needed
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting cache dir: %w", err)
+	}
+
+	dir = filepath.Join(dir, appName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating cache dir: %w", err)
+	}
+
+	return dir, nil
+}
+
+// dataDir returns the data directory of the app, creating it if needed. It
+// follows $XDG_DATA_HOME on unix-like systems.
+func dataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error getting data dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+
+	if runtime.GOOS == "windows" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", fmt.Errorf("error getting data dir: %w", err)
+		}
+	}
+
+	dir = filepath.Join(dir, appName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating data dir: %w", err)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haaag/GoURL/extract"
+)
+
+// composeCmd returns the command used to compose emails: --compose-cmd,
+// $MAILER or xdg-email
+func composeCmd() []string {
+	s := composeFlag
+	if s == "" {
+		s = os.Getenv("MAILER")
+	}
+	if s == "" {
+		s = "xdg-email"
+	}
+
+	return commandArgs(s)
+}
+
+// composeEmail opens a compose window in the mail client for the mailto URL
+func composeEmail(url string) error {
+	args := composeCmd()
+	args = append(args, url)
+	cmd := exec.Command(args[0], args[1:]...)
+	log.Printf("composing email to %s with '%s'\n", url, cmd.Args)
+	if err := startCmd(cmd); err != nil {
+		return fmt.Errorf("error composing email: %w", err)
+	}
+
+	return nil
+}
+
+const verifyEmailTimeout = 10 * time.Second
+
+var (
+	// obfuscatedAt matches '@' written like ' [at] ', '(at)' or '{@}'
+	obfuscatedAt = regexp.MustCompile(`(?i)\s*[\[({<]\s*(?:at|@)\s*[\])}>]\s*`)
+	// obfuscatedDot matches '.' written like ' [dot] ', '(dot)' or '[.]'
+	obfuscatedDot = regexp.MustCompile(`(?i)\s*[\[({<]\s*(?:dot|\.)\s*[\])}>]\s*`)
+)
+
+// deobfuscateEmails returns the line with the HTML entities decoded and the
+// common obfuscations of '@' and '.' replaced, so hidden addresses like
+// 'name [at] example [dot] com' can be found
+func deobfuscateEmails(line string) string {
+	if strings.Contains(line, "&") {
+		line = html.UnescapeString(line)
+	}
+
+	// the obfuscations are in brackets, most lines have none and are not
+	// copied by the replacements
+	if !strings.ContainsAny(line, "[({<") {
+		return line
+	}
+
+	line = obfuscatedAt.ReplaceAllString(line, "@")
+	return obfuscatedDot.ReplaceAllString(line, ".")
+}
+
+// newEmailFinder returns the finder of email addresses, including the
+// obfuscated ones, but not the users and passwords of URLs
+func newEmailFinder() func(string) []string {
+	return func(line string) []string {
+		line = deobfuscateEmails(line)
+		locs := extract.EmailIndex(line)
+		if len(locs) == 0 {
+			return nil
+		}
+		emails := make([]string, 0, len(locs))
+		for _, loc := range locs {
+			emails = append(emails, "mailto:"+line[loc[0]:loc[1]])
+		}
+		return emails
+	}
+}
+
+// emailDomain returns the domain of the mailto URL or address
+func emailDomain(s string) string {
+	s = strings.TrimPrefix(s, "mailto:")
+	s, _, _ = strings.Cut(s, "?")
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		s = s[i+1:]
+	}
+
+	return strings.ToLower(s)
+}
+
+// acceptsMail reports whether the domain has MX records, or A/AAAA records
+// used when there is no MX. Errors are only returned for failed lookups,
+// not for domains without records.
+func acceptsMail(domain string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyEmailTimeout)
+	defer cancel()
+
+	notFound := func(err error) bool {
+		var dnsErr *net.DNSError
+		return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+	}
+
+	mx, err := net.DefaultResolver.LookupMX(ctx, domain)
+	if err != nil && !notFound(err) {
+		return false, fmt.Errorf("error looking up %s: %w", domain, err)
+	}
+	for _, r := range mx {
+		// a null MX (RFC 7505) means the domain does not accept mail
+		if r.Host == "." {
+			return false, nil
+		}
+	}
+	if len(mx) > 0 {
+		return true, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, domain)
+	if err != nil && !notFound(err) {
+		return false, fmt.Errorf("error looking up %s: %w", domain, err)
+	}
+
+	return len(addrs) > 0, nil
+}
+
+// verifyEmails removes the emails whose domain does not accept mail, each
+// domain is looked up once. Emails are kept when the lookup fails.
+func verifyEmails(items []Match) []Match {
+	domains := make(map[string]bool)
+	var pending []string
+	for i := range items {
+		if items[i].Type != typeEmail {
+			continue
+		}
+		if d := emailDomain(items[i].Value); !domains[d] {
+			domains[d] = true
+			pending = append(pending, d)
+		}
+	}
+
+	var mu sync.Mutex
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < checkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range ch {
+				ok, err := acceptsMail(domain)
+				if err != nil {
+					log.Print(err)
+					ok = true
+				}
+				mu.Lock()
+				domains[domain] = ok
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, domain := range pending {
+		ch <- domain
+	}
+	close(ch)
+	wg.Wait()
+
+	var result []Match
+	for _, m := range items {
+		if m.Type == typeEmail && !domains[emailDomain(m.Value)] {
+			log.Printf("invalid email: %s", m.Value)
+			continue
+		}
+		result = append(result, m)
+	}
+
+	return result
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEmbedDepth is how many times URLs decoded from other URLs are decoded
+// again, for redirects wrapping redirects
+const maxEmbedDepth = 3
+
+// base64Blob matches the base64 and base64url strings long enough to hold
+// a URL, padding included
+var base64Blob = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+
+// decodeBase64 decodes the standard or URL-safe base64 string, with or
+// without padding
+func decodeBase64(s string) ([]byte, bool) {
+	s = strings.TrimRight(s, "=")
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(s)
+	}
+	if err != nil || !utf8.Valid(b) {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// embeddedURLs returns the URLs found in the base64 blobs of the URL, like
+// the target of 'https://t.example/r?u=aHR0cHM6Ly9leGFtcGxlLmNvbQ'
+func embeddedURLs(rawURL string, find func(string) []string) []string {
+	s := rawURL
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		s = unescaped
+	}
+
+	var found []string
+	for _, blob := range base64Blob.FindAllString(s, -1) {
+		// '/' is a base64 char but also splits the path, so the blob may
+		// start after any of them
+		for blob != "" {
+			if b, ok := decodeBase64(blob); ok {
+				if urls := find(string(b)); len(urls) > 0 {
+					found = append(found, urls...)
+					break
+				}
+			}
+			_, blob, _ = strings.Cut(blob, "/")
+			if len(blob) < 16 {
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// decodeEmbedded adds the URLs encoded in base64 inside the items after
+// them, with the item they were found in as their parent
+func (o *Options) decodeEmbedded(items []Match) []Match {
+	find := o.newURLFinder()
+	result := make([]Match, 0, len(items))
+	var add func(m Match, depth int)
+	add = func(m Match, depth int) {
+		result = append(result, m)
+		if depth == maxEmbedDepth {
+			return
+		}
+		for _, u := range embeddedURLs(m.Value, find) {
+			if u == m.Value {
+				continue
+			}
+			add(Match{Value: u, Source: m.Source, Line: m.Line, Type: classify(u), Parent: m.Value}, depth+1)
+		}
+	}
+
+	for i := range items {
+		add(items[i], 0)
+	}
+
+	return result
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// input encodings
+const (
+	encAuto        = "auto"
+	encUTF8        = "utf-8"
+	encUTF16LE     = "utf-16le"
+	encUTF16BE     = "utf-16be"
+	encLatin1      = "latin1"
+	encWindows1252 = "windows-1252"
+)
+
+var encodings = []string{encAuto, encUTF8, encUTF16LE, encUTF16BE, encLatin1, encWindows1252}
+
+var errEncoding = errors.New("unknown encoding")
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// validateEncoding checks the value of the --encoding flag
+func validateEncoding(s string) error {
+	for _, e := range encodings {
+		if strings.EqualFold(s, e) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q (valid: %s)", errEncoding, s, strings.Join(encodings, ", "))
+}
+
+// validUTF8Prefix reports whether b is valid UTF-8, ignoring a rune cut at
+// the end of the buffer
+func validUTF8Prefix(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return true
+		}
+		b = b[:len(b)-1]
+	}
+
+	return utf8.Valid(b)
+}
+
+// detectEncoding guesses the encoding of the data from its BOM, the position
+// of NUL bytes and the UTF-8 validity
+func detectEncoding(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return encUTF8
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return encUTF16LE
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return encUTF16BE
+	}
+
+	// ASCII text encoded as UTF-16 has a NUL in every other byte
+	var even, odd int
+	for i, c := range head {
+		if c != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	if half := len(head) / 4; half > 0 {
+		switch {
+		case odd > half && even == 0:
+			return encUTF16LE
+		case even > half && odd == 0:
+			return encUTF16BE
+		}
+	}
+
+	if !validUTF8Prefix(head) {
+		return encWindows1252
+	}
+
+	return encUTF8
+}
+
+// windows1252 maps the bytes 0x80-0x9f to their runes, the rest of the
+// code page matches latin-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// decodeSingleByte decodes latin-1 or windows-1252 text to UTF-8
+func decodeSingleByte(b []byte, enc string) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(b))
+	for _, c := range b {
+		r := rune(c)
+		if enc == encWindows1252 && c >= 0x80 && c <= 0x9f {
+			r = windows1252[c-0x80]
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.Bytes()
+}
+
+// decodeUTF16 decodes UTF-16 text to UTF-8, removing the BOM
+func decodeUTF16(b []byte, enc string) []byte {
+	if bytes.HasPrefix(b, bomUTF16LE) || bytes.HasPrefix(b, bomUTF16BE) {
+		b = b[2:]
+	}
+
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		if enc == encUTF16LE {
+			u = append(u, uint16(b[i])|uint16(b[i+1])<<8)
+		} else {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+	}
+
+	return []byte(string(utf16.Decode(u)))
+}
+
+// transcode returns a reader with the input converted to UTF-8
+func transcode(r io.Reader, enc string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	enc = strings.ToLower(enc)
+	if enc == encAuto {
+		head, _ := br.Peek(sniffLen)
+		enc = detectEncoding(head)
+	}
+
+	if enc == encUTF8 {
+		if head, _ := br.Peek(len(bomUTF8)); bytes.Equal(head, bomUTF8) {
+			_, _ = br.Discard(len(bomUTF8))
+		}
+		return br, nil
+	}
+
+	log.Printf("input encoding: %s", enc)
+	b, err := io.ReadAll(br)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	switch enc {
+	case encUTF16LE, encUTF16BE:
+		b = decodeUTF16(b, enc)
+	case encLatin1, encWindows1252:
+		b = decodeSingleByte(b, enc)
+	}
+
+	return bytes.NewReader(b), nil
+}
@@ -0,0 +1,399 @@
+// Package extract finds URLs and email addresses in text streams, it is
+// the extractor used by gourl.
+//
+//	sc := extract.NewScanner(r)
+//	for sc.Scan() {
+//		fmt.Println(sc.Match().Value)
+//	}
+//	if err := sc.Err(); err != nil {
+//		...
+//	}
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	// URLPattern matches the URLs with the default schemes and the ones
+	// starting with www., in any case
+	URLPattern = `(((?i:http|https|gopher|gemini|ftp|ftps|git)://|(?i:www)\.)` + URLPathPattern + `)`
+	// URLPathPattern matches the rest of the URL after the scheme, the
+	// trailing punctuation is trimmed with TrimURL
+	URLPathPattern = `(?:\[[0-9A-Fa-f:.]+\]|[\p{L}\p{N}\p{M}.]*)[:;\p{L}\p{N}\p{M}./+@$&%?$\#=_~()!*,'-]*`
+	// EmailPattern matches email addresses
+	EmailPattern = `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`
+)
+
+// DefaultSchemes are the schemes matched by URLPattern
+var DefaultSchemes = []string{"http", "https", "gopher", "gemini", "ftp", "ftps", "git"}
+
+// DefaultMaxLineBytes is the length lines are split at by default
+const DefaultMaxLineBytes = 1 << 20
+
+// ErrNoSchemes is returned by NewScanner when WithSchemes allows none
+var ErrNoSchemes = errors.New("extract: no schemes allowed")
+
+// URLPatternFor returns the URL regex matching the schemes, and www. when
+// www is set, in any case
+func URLPatternFor(schemes []string, www bool) string {
+	alts := make([]string, 0, len(schemes)+1)
+	for _, s := range schemes {
+		alts = append(alts, `(?i:`+regexp.QuoteMeta(strings.ToLower(s))+`)://`)
+	}
+	if www {
+		alts = append(alts, `(?i:www)\.`)
+	}
+	if len(alts) == 0 {
+		// nothing is allowed, the regex never matches
+		return `[^\x00-\x{10FFFF}]`
+	}
+
+	return `((` + strings.Join(alts, "|") + `)` + URLPathPattern + `)`
+}
+
+// Kind is the kind of a match
+type Kind int
+
+const (
+	KindURL Kind = iota
+	KindEmail
+	// KindCustom is a match of the regex given with WithPattern
+	KindCustom
+)
+
+// Match is a URL or email found in the input
+type Match struct {
+	// Value is the URL, emails get the mailto: prefix
+	Value string
+	Kind  Kind
+	// Line is the number of the line, from 1
+	Line int
+	// Start and End are the byte offsets of the match in the line
+	Start, End int
+}
+
+type options struct {
+	pattern *regexp.Regexp
+	schemes []string
+	maxLine int
+	emails  bool
+}
+
+// Option configures a Scanner
+type Option func(*options)
+
+// WithEmails sets whether email addresses are found, they are by default
+func WithEmails(on bool) Option {
+	return func(o *options) { o.emails = on }
+}
+
+// WithSchemes limits the URLs found to the schemes, www. is found while
+// http is in the list. With no schemes NewScanner fails with ErrNoSchemes.
+func WithSchemes(schemes ...string) Option {
+	return func(o *options) { o.schemes = append(make([]string, 0, len(schemes)), schemes...) }
+}
+
+// WithPattern finds the matches of the regex instead of URLs and emails
+func WithPattern(re *regexp.Regexp) Option {
+	return func(o *options) { o.pattern = re }
+}
+
+// WithMaxLineBytes splits the lines longer than n bytes where LineCut
+// does, so the matches are not cut, and scans the parts in turn. The scan
+// fails with bufio.ErrTooLong at n bytes without any place to cut.
+func WithMaxLineBytes(n int) Option {
+	return func(o *options) { o.maxLine = n }
+}
+
+// location is a match in the current line
+type location struct {
+	start, end int
+	kind       Kind
+}
+
+// Scanner reads the matches of a stream one at a time, the input is read
+// a line at a time as the matches are consumed
+type Scanner struct {
+	r      *bufio.Reader
+	url    *regexp.Regexp
+	email  *regexp.Regexp
+	custom *regexp.Regexp
+	// line holds the part of the current line being scanned, up to end,
+	// and the rest read of a long line
+	line []byte
+	end  int
+	// base is the offset of line in the current line
+	base int
+	// more is set while the reader is in the middle of a line
+	more    bool
+	pending []location
+	next    int
+	cur     location
+	num     int
+	maxLine int
+	err     error
+}
+
+// NewScanner returns a Scanner reading from r
+func NewScanner(r io.Reader, opts ...Option) *Scanner {
+	o := options{emails: true, maxLine: DefaultMaxLineBytes}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &Scanner{r: bufio.NewReader(r), maxLine: max(o.maxLine, 2)}
+	switch {
+	case o.pattern != nil:
+		s.custom = o.pattern
+	case o.schemes != nil:
+		var schemes []string
+		var www bool
+		for _, scheme := range o.schemes {
+			schemes = append(schemes, scheme)
+			www = www || strings.EqualFold(scheme, "http")
+		}
+		if len(schemes) == 0 {
+			s.err = ErrNoSchemes
+		}
+		s.url = regexp.MustCompile(URLPatternFor(schemes, www))
+	default:
+		s.url = urlRegexp
+	}
+	if o.emails && o.pattern == nil {
+		s.email = emailRegexp
+	}
+
+	return s
+}
+
+var (
+	urlRegexp   = regexp.MustCompile(URLPattern)
+	emailRegexp = regexp.MustCompile(EmailPattern)
+)
+
+// Scan advances to the next match, false at the end of the input or on a
+// read error
+func (s *Scanner) Scan() bool {
+	for s.next == len(s.pending) {
+		if !s.readLine() {
+			return false
+		}
+		s.find()
+	}
+
+	s.cur = s.pending[s.next]
+	s.next++
+
+	return true
+}
+
+// Bytes returns the text of the current match as written, without the
+// mailto: prefix of emails. It is only valid until the next call to Scan
+// and does not allocate.
+func (s *Scanner) Bytes() []byte {
+	return s.line[s.cur.start:s.cur.end]
+}
+
+// Match returns the current match, URLs with their scheme in lowercase
+func (s *Scanner) Match() Match {
+	value := string(s.Bytes())
+	switch s.cur.kind {
+	case KindEmail:
+		value = "mailto:" + value
+	case KindURL:
+		value = LowerScheme(value)
+	}
+
+	return Match{Value: value, Kind: s.cur.kind, Line: s.num, Start: s.base + s.cur.start, End: s.base + s.cur.end}
+}
+
+// Err returns the first error reading the input, nil at io.EOF
+func (s *Scanner) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+
+	return s.err
+}
+
+// readLine reads the next line into the line buffer, which is reused. The
+// lines longer than maxLine are scanned in parts, the rest is kept in the
+// buffer and read as the next part of the same line.
+func (s *Scanner) readLine() bool {
+	cont := s.end < len(s.line) || s.more
+	if cont {
+		s.base += s.end
+		s.line = s.line[:copy(s.line, s.line[s.end:])]
+	} else {
+		if s.err != nil {
+			return false
+		}
+		s.line, s.base = s.line[:0], 0
+	}
+
+	for (s.more || !cont) && len(s.line) <= s.maxLine {
+		frag, isPrefix, err := s.r.ReadLine()
+		if err != nil {
+			s.err, s.more = err, false
+			if !cont && len(s.line) == 0 {
+				return false
+			}
+			break
+		}
+		s.line = append(s.line, frag...)
+		s.more, cont = isPrefix, true
+	}
+	if s.base == 0 {
+		s.num++
+	}
+
+	s.end = len(s.line)
+	if s.end > s.maxLine {
+		if s.end = LineCut(s.line, s.maxLine); s.end < 0 {
+			s.err, s.line, s.end, s.more = bufio.ErrTooLong, s.line[:0], 0, false
+			return false
+		}
+	}
+
+	return true
+}
+
+// lineCuts are the bytes long lines are cut at, blanks and the delimiters
+// around links, which cannot be part of a match
+const lineCuts = " \t\"<>`{}|^"
+
+// LineCut returns the offset a line longer than size bytes is cut at,
+// before the last blank or delimiter around links up to offset size, so no
+// match spans the cut. It returns -1 when there is none.
+func LineCut(line []byte, size int) int {
+	if i := bytes.LastIndexAny(line[1:size+1], lineCuts); i >= 0 {
+		return i + 1
+	}
+
+	return -1
+}
+
+// MayContainURL reports whether s can have a match of URLPattern, or of a
+// URLPatternFor pattern, all of them have "://" or "www." in any case. It
+// is much faster than the regexes, the text without it can be skipped.
+func MayContainURL(s string) bool {
+	if strings.Contains(s, "://") {
+		return true
+	}
+	for i := strings.IndexByte(s, '.'); i >= 0; {
+		if i >= 3 && strings.EqualFold(s[i-3:i], "www") {
+			return true
+		}
+		j := strings.IndexByte(s[i+1:], '.')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+
+	return false
+}
+
+// hasWWW reports whether b contains "www." in any case, looking at the
+// dots instead of comparing at every offset
+func hasWWW(b []byte) bool {
+	for i := bytes.IndexByte(b, '.'); i >= 0; {
+		if i >= 3 && bytes.EqualFold(b[i-3:i], []byte("www")) {
+			return true
+		}
+		j := bytes.IndexByte(b[i+1:], '.')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+
+	return false
+}
+
+// find adds the matches of the line to pending, in the order they appear.
+// Emails overlapping a URL are not found.
+func (s *Scanner) find() {
+	s.pending, s.next = s.pending[:0], 0
+	line := s.line[:s.end]
+	if s.custom != nil {
+		for _, loc := range s.custom.FindAllIndex(line, -1) {
+			s.pending = append(s.pending, location{loc[0], loc[1], KindCustom})
+		}
+		return
+	}
+
+	if bytes.Contains(line, []byte("://")) || hasWWW(line) {
+		for _, loc := range s.url.FindAllIndex(line, -1) {
+			end := loc[0] + trimmedLen(line[loc[0]:loc[1]])
+			if !isEmptyURL(line[loc[0]:end]) {
+				s.pending = append(s.pending, location{loc[0], end, KindURL})
+			}
+		}
+	}
+
+	if s.email == nil || bytes.IndexByte(line, '@') < 0 {
+		return
+	}
+	urls := len(s.pending)
+	for _, loc := range s.email.FindAllIndex(line, -1) {
+		if !inURL(loc, s.pending[:urls]) {
+			s.pending = append(s.pending, location{loc[0], loc[1], KindEmail})
+		}
+	}
+
+	// keep the input order when there are emails after the URLs
+	if len(s.pending) > urls && urls > 0 {
+		sortLocations(s.pending)
+	}
+}
+
+// inURL reports whether the match at loc overlaps one of the URLs, like
+// the user of https://user@example.com/, which is not an email
+func inURL(loc []int, urls []location) bool {
+	for _, u := range urls {
+		if loc[0] < u.end && loc[1] > u.start {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EmailIndex returns the locations of the email addresses in s, like
+// FindAllStringIndex, without the ones that are part of a URL
+func EmailIndex(s string) [][]int {
+	emails := emailRegexp.FindAllStringIndex(s, -1)
+	if len(emails) == 0 || !MayContainURL(s) {
+		return emails
+	}
+
+	var urls []location
+	for _, loc := range urlRegexp.FindAllStringIndex(s, -1) {
+		urls = append(urls, location{loc[0], loc[0] + trimmedLen(s[loc[0]:loc[1]]), KindURL})
+	}
+	kept := emails[:0]
+	for _, loc := range emails {
+		if !inURL(loc, urls) {
+			kept = append(kept, loc)
+		}
+	}
+
+	return kept
+}
+
+// sortLocations sorts the locations by offset, with an insertion sort since
+// lines have few matches
+func sortLocations(locs []location) {
+	for i := 1; i < len(locs); i++ {
+		for j := i; j > 0 && locs[j].start < locs[j-1].start; j-- {
+			locs[j], locs[j-1] = locs[j-1], locs[j]
+		}
+	}
+}
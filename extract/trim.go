@@ -0,0 +1,101 @@
+package extract
+
+import "strings"
+
+// trailingPunct are trimmed from the end of the URLs, they end sentences
+// and quotes more often than URLs
+const trailingPunct = ".,:;!?'*"
+
+// trimmedLen returns the length of the URL without the trailing
+// punctuation and the closing parentheses not opened in it, so URLs in
+// parentheses and at the end of sentences are found without them
+func trimmedLen[T string | []byte](s T) int {
+	// the dot of www. is part of the URL
+	keep := 0
+	if len(s) >= 4 && s[0]|0x20 == 'w' && s[1]|0x20 == 'w' && s[2]|0x20 == 'w' && s[3] == '.' {
+		keep = 4
+	}
+
+	n := len(s)
+	for n > keep {
+		c := s[n-1]
+		switch {
+		case isTrailingPunct(c):
+			n--
+		case c == ')' && unbalanced(s[:n]):
+			n--
+		default:
+			return n
+		}
+	}
+
+	return n
+}
+
+func isTrailingPunct(c byte) bool {
+	for i := 0; i < len(trailingPunct); i++ {
+		if trailingPunct[i] == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unbalanced reports whether s has more closing than opening parentheses
+func unbalanced[T string | []byte](s T) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+
+	return depth < 0
+}
+
+// isEmptyURL reports whether the trimmed URL is only its scheme or www.,
+// like the "https://" of a text about schemes
+func isEmptyURL[T string | []byte](s T) bool {
+	if len(s) == 4 {
+		return s[3] == '.'
+	}
+	for i := 0; i+3 <= len(s); i++ {
+		if s[i] == ':' && s[i+1] == '/' && s[i+2] == '/' {
+			return i+3 == len(s)
+		}
+	}
+
+	return false
+}
+
+// IsEmptyURL reports whether the URL trimmed with TrimURL has nothing after
+// its scheme or www., which is not a link
+func IsEmptyURL(s string) bool {
+	return isEmptyURL(s)
+}
+
+// TrimURL returns the URL without the trailing punctuation and unbalanced
+// closing parentheses
+func TrimURL(s string) string {
+	return s[:trimmedLen(s)]
+}
+
+// LowerScheme returns the URL with its scheme in lowercase, like
+// 'HTTP://Example.com' to 'http://Example.com'
+func LowerScheme(s string) string {
+	i := strings.Index(s, "://")
+	if i <= 0 || strings.ToLower(s[:i]) == s[:i] {
+		return s
+	}
+	for _, c := range s[:i] {
+		if !('a' <= c|0x20 && c|0x20 <= 'z' || '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.') {
+			return s
+		}
+	}
+
+	return strings.ToLower(s[:i]) + s[i:]
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// faviconSize is the largest side of the cached favicons, in pixels
+const faviconSize = 64
+
+// itemHome returns the home page of an http or https item, nil for the
+// others
+func itemHome(s string) *url.URL {
+	u, err := parseURL(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil
+	}
+
+	return &url.URL{Scheme: u.Scheme, Host: strings.ToLower(u.Host), Path: "/"}
+}
+
+// fetchFavicon returns the path of the favicon of the site, fetching it
+// from the icon of its home page when it is not cached
+func fetchFavicon(client *http.Client, home *url.URL) (string, error) {
+	path, err := thumbnailPath("favicons", home.Host)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	icon := home.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	if resp, b, err := fetchBody(client, home.String(), previewMaxPage); err == nil {
+		if u := resolveRef(resp.Request.URL, pageIcon(string(b))); u != "" {
+			icon = u
+		}
+	}
+
+	_, b, err := fetchBody(client, icon, previewMaxImage)
+	if err != nil {
+		return "", err
+	}
+
+	if err := saveThumbnail(path, b, faviconSize); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// favicons returns the path of the favicon of each host of the items,
+// hosts without one are left out
+func favicons(items []Match) map[string]string {
+	var homes []*url.URL
+	seen := make(map[string]bool)
+	for i := range items {
+		if h := itemHome(items[i].Value); h != nil && !seen[h.Host] {
+			seen[h.Host] = true
+			homes = append(homes, h)
+		}
+	}
+
+	client := newHTTPClient(previewTimeout)
+	paths := make(map[string]string, len(homes))
+	var mu sync.Mutex
+	ch := make(chan *url.URL)
+	var wg sync.WaitGroup
+	for w := 0; w < checkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for home := range ch {
+				path, err := fetchFavicon(client, home)
+				if err != nil {
+					log.Printf("favicon of %s: %s", home.Host, err)
+					continue
+				}
+				mu.Lock()
+				paths[home.Host] = path
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, h := range homes {
+		ch <- h
+	}
+	close(ch)
+	wg.Wait()
+
+	return paths
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// forge kinds of reference
+const (
+	refRepo   = "repo"
+	refIssue  = "issue"
+	refPull   = "pr"
+	refMR     = "mr"
+	refCommit = "commit"
+)
+
+// forgeRef is a reference to an object hosted in a git forge
+type forgeRef struct {
+	Forge string
+	Repo  string
+	Kind  string
+	ID    string
+}
+
+// label returns a concise description of the reference
+func (r *forgeRef) label() string {
+	switch r.Kind {
+	case refRepo:
+		return fmt.Sprintf("%s:%s (%s)", r.Forge, r.Repo, r.Kind)
+	case refCommit:
+		return fmt.Sprintf("%s:%s@%.7s (%s)", r.Forge, r.Repo, r.ID, r.Kind)
+	default:
+		return fmt.Sprintf("%s:%s#%s (%s)", r.Forge, r.Repo, r.ID, r.Kind)
+	}
+}
+
+// forgeHosts maps the forge hosts to their names
+var forgeHosts = map[string]string{
+	"github.com":   "github",
+	"gitlab.com":   "gitlab",
+	"codeberg.org": "codeberg",
+}
+
+// parseForgeURL recognizes repository, issue, pull request and commit URLs
+// of GitHub, GitLab and Codeberg
+func parseForgeURL(s string) (*forgeRef, bool) {
+	u, err := parseURL(s)
+	if err != nil {
+		return nil, false
+	}
+
+	forge, ok := forgeHosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")]
+	if !ok {
+		return nil, false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if forge == "gitlab" {
+		return parseGitLabPath(parts)
+	}
+
+	if len(parts) < 2 || parts[0] == "" {
+		return nil, false
+	}
+
+	ref := &forgeRef{Forge: forge, Repo: parts[0] + "/" + strings.TrimSuffix(parts[1], ".git"), Kind: refRepo}
+	if len(parts) == 2 {
+		return ref, true
+	}
+
+	if len(parts) < 4 {
+		return nil, false
+	}
+
+	ref.ID = parts[3]
+	switch parts[2] {
+	case "issues":
+		ref.Kind = refIssue
+	case "pull", "pulls":
+		ref.Kind = refPull
+	case "commit":
+		ref.Kind = refCommit
+	default:
+		return nil, false
+	}
+
+	return ref, true
+}
+
+// parseGitLabPath parses GitLab paths, where projects can be nested in
+// groups and the object is separated by '/-/'
+func parseGitLabPath(parts []string) (*forgeRef, bool) {
+	sep := -1
+	for i, p := range parts {
+		if p == "-" {
+			sep = i
+			break
+		}
+	}
+
+	if sep < 0 {
+		if len(parts) < 2 {
+			return nil, false
+		}
+		return &forgeRef{Forge: "gitlab", Repo: strings.Join(parts, "/"), Kind: refRepo}, true
+	}
+
+	if sep < 2 || len(parts) < sep+3 {
+		return nil, false
+	}
+
+	ref := &forgeRef{Forge: "gitlab", Repo: strings.Join(parts[:sep], "/"), ID: parts[sep+2]}
+	switch parts[sep+1] {
+	case "issues":
+		ref.Kind = refIssue
+	case "merge_requests":
+		ref.Kind = refMR
+	case "commit":
+		ref.Kind = refCommit
+	default:
+		return nil, false
+	}
+
+	return ref, true
+}
+
+// apiURL returns the public API endpoint with the title of the reference
+func (r *forgeRef) apiURL() string {
+	switch r.Forge {
+	case "github":
+		switch r.Kind {
+		case refCommit:
+			return "https://api.github.com/repos/" + r.Repo + "/commits/" + r.ID
+		case refRepo:
+			return "https://api.github.com/repos/" + r.Repo
+		}
+		// pull requests are issues too
+		return "https://api.github.com/repos/" + r.Repo + "/issues/" + r.ID
+	case "gitlab":
+		base := "https://gitlab.com/api/v4/projects/" + url.PathEscape(r.Repo)
+		switch r.Kind {
+		case refIssue:
+			return base + "/issues/" + r.ID
+		case refMR:
+			return base + "/merge_requests/" + r.ID
+		case refCommit:
+			return base + "/repository/commits/" + r.ID
+		}
+		return base
+	case "codeberg":
+		base := "https://codeberg.org/api/v1/repos/" + r.Repo
+		switch r.Kind {
+		case refCommit:
+			return base + "/git/commits/" + r.ID
+		case refRepo:
+			return base
+		}
+		return base + "/issues/" + r.ID
+	}
+
+	return ""
+}
+
+// forgeResponse holds the fields used as title in the API responses
+type forgeResponse struct {
+	Commit *struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+	Title       string `json:"title"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+}
+
+// title returns the first line of the title found in the response
+func (f *forgeResponse) title() string {
+	var s string
+	switch {
+	case f.Title != "":
+		s = f.Title
+	case f.Commit != nil && f.Commit.Message != "":
+		s = f.Commit.Message
+	case f.Message != "":
+		s = f.Message
+	default:
+		s = f.Description
+	}
+
+	s, _, _ = strings.Cut(s, "\n")
+	return strings.TrimSpace(s)
+}
+
+const (
+	forgeTimeout  = 5 * time.Second
+	forgeCacheTTL = 7 * 24 * time.Hour
+	forgeWorkers  = 4
+)
+
+// forgeCacheEntry is a title stored in the cache
+type forgeCacheEntry struct {
+	Time  time.Time `json:"time"`
+	Title string    `json:"title"`
+}
+
+// forgeCache stores the titles fetched from the forges APIs
+type forgeCache struct {
+	entries map[string]forgeCacheEntry
+	path    string
+	mu      sync.Mutex
+	dirty   bool
+}
+
+// loadForgeCache reads the cache from disk, a missing or invalid cache is
+// treated as empty
+func loadForgeCache() *forgeCache {
+	c := &forgeCache{entries: make(map[string]forgeCacheEntry)}
+	dir, err := cacheDir()
+	if err != nil {
+		log.Print(err)
+		return c
+	}
+
+	c.path = filepath.Join(dir, "forge.json")
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		log.Printf("invalid forge cache: %s", err)
+	}
+
+	return c
+}
+
+func (c *forgeCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.Time) > forgeCacheTTL {
+		return "", false
+	}
+
+	return e.Title, true
+}
+
+func (c *forgeCache) set(key, title string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = forgeCacheEntry{Title: title, Time: time.Now()}
+	c.dirty = true
+}
+
+// save writes the cache to disk if it changed
+func (c *forgeCache) save() {
+	if !c.dirty || c.path == "" {
+		return
+	}
+
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		log.Printf("error encoding forge cache: %s", err)
+		return
+	}
+
+	if err := os.WriteFile(c.path, b, 0o600); err != nil {
+		log.Printf("error writing forge cache: %s", err)
+	}
+}
+
+// fetchForgeTitle gets the title of the reference from the forge API
+func fetchForgeTitle(client *http.Client, ref *forgeRef) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, ref.apiURL(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && ref.Forge == "github" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching title: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching title: %w: %s", errHTTPStatus, resp.Status)
+	}
+
+	var f forgeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return f.title(), nil
+}
+
+// enrichForge sets the label of the items that point to a git forge, with
+// the title fetched from the public API when useAPI is set
+func enrichForge(items []Match, useAPI bool) {
+	type job struct {
+		ref *forgeRef
+		i   int
+	}
+
+	var jobs []job
+	for i := range items {
+		ref, ok := parseForgeURL(items[i].Value)
+		if !ok {
+			continue
+		}
+		items[i].Label = ref.label()
+		jobs = append(jobs, job{ref: ref, i: i})
+	}
+
+	if !useAPI || len(jobs) == 0 {
+		return
+	}
+
+	cache := loadForgeCache()
+	defer cache.save()
+
+	client := newHTTPClient(forgeTimeout)
+	ch := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < forgeWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				key := j.ref.apiURL()
+				title, ok := cache.get(key)
+				if !ok {
+					var err error
+					title, err = fetchForgeTitle(client, j.ref)
+					if err != nil {
+						log.Printf("%s: %s", j.ref.label(), err)
+						continue
+					}
+					cache.set(key, title)
+				}
+
+				if title != "" {
+					items[j.i].Label += " " + title
+				}
+			}
+		}()
+	}
+
+	for _, j := range jobs {
+		ch <- j
+	}
+	close(ch)
+	wg.Wait()
+}
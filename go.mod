@@ -2,4 +2,10 @@ module github.com/haaag/GoURL
 
 go 1.21.3
 
-require github.com/atotto/clipboard v0.1.4
+require (
+	github.com/atotto/clipboard v0.1.4
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
+	golang.org/x/term v0.0.0-20220526004731-065cf7ba2467
+)
+
+require golang.org/x/sys v0.15.0 // indirect
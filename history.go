@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// HistoryEntry is a selection stored in the history
+type HistoryEntry struct {
+	Time   time.Time `json:"time"`
+	URL    string    `json:"url"`
+	Action string    `json:"action"`
+	Source string    `json:"source,omitempty"`
+}
+
+// historyPath returns the path of the history file
+func historyPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// addHistory appends the selected item to the history, when it is enabled
+// with --history or in the config
+func addHistory(m *Match, action string) {
+	if !historyFlag || noHistoryFlag {
+		return
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		log.Printf("error saving history: %s", err)
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("error saving history: %s", err)
+		return
+	}
+	defer f.Close()
+
+	e := HistoryEntry{Time: time.Now(), URL: m.Value, Action: action, Source: m.Source}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		log.Printf("error saving history: %s", err)
+	}
+}
+
+// readHistory returns the entries of the history, oldest first
+func readHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	defer f.Close()
+
+	return decodeHistory(f)
+}
+
+// decodeHistory reads history entries in JSON lines format, skipping the
+// invalid ones
+func decodeHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var e HistoryEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.URL == "" {
+			log.Printf("history: skipping invalid entry at line %d", n)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// parseSince parses durations like 90m, 12h, 7d or 2w
+func parseSince(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if n := len(s); n > 1 {
+		if unit, ok := units[s[n-1]]; ok {
+			v, err := strconv.Atoi(s[:n-1])
+			if err != nil || v < 0 || time.Duration(v) > math.MaxInt64/unit {
+				return 0, fmt.Errorf("%w: %q", errInvalidDuration, s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q", errInvalidDuration, s)
+	}
+
+	return d, nil
+}
+
+// historyFilter holds the filters for history searches
+type historyFilter struct {
+	since  time.Time
+	search string
+	domain string
+}
+
+// match reports whether the entry passes the filter
+func (f *historyFilter) match(e *HistoryEntry) bool {
+	if !f.since.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+
+	if f.search != "" && !strings.Contains(strings.ToLower(e.URL), f.search) {
+		return false
+	}
+
+	if f.domain != "" {
+		u, err := parseURL(e.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		if host != f.domain && !strings.HasSuffix(host, "."+f.domain) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// runHistory runs the history subcommand, printing the matching entries
+// newest first
+func runHistory(_ *Options, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runHistoryExport(args[1:])
+		case "import":
+			return runHistoryImport(args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	search := fs.String("search", "", "only entries containing text")
+	since := fs.String("since", "", "only entries newer than duration (e.g. 12h, 7d, 2w)")
+	domain := fs.String("domain", "", "only entries of domain")
+	asJSON := fs.Bool("json", false, "output entries as JSON lines")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history [options]\n\nOptions:\n", appName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	f := historyFilter{
+		search: strings.ToLower(*search),
+		domain: strings.TrimPrefix(strings.ToLower(*domain), "www."),
+	}
+	if *since != "" {
+		d, err := parseSince(*since)
+		if err != nil {
+			return err
+		}
+		f.since = time.Now().Add(-d)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	seen := make(map[string]bool)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if !f.match(e) {
+			continue
+		}
+
+		if *asJSON {
+			if err := enc.Encode(e); err != nil {
+				return fmt.Errorf("error encoding entry: %w", err)
+			}
+			continue
+		}
+
+		if !seen[e.URL] {
+			seen[e.URL] = true
+			fmt.Println(e.URL)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// historySchema identifies exported histories, historyVersion is bumped on
+// incompatible changes of the entries
+const (
+	historySchema  = "gourl-history"
+	historyVersion = 1
+)
+
+// history export formats
+const (
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+var (
+	errHistoryFormat  = errors.New("unknown history format")
+	errHistoryVersion = errors.New("unsupported history version")
+)
+
+var historyCSVHeader = []string{"time", "url", "action", "source"}
+
+// historyHeader is the first line of an exported history in JSON lines
+type historyHeader struct {
+	Schema  string `json:"schema"`
+	Version int    `json:"version"`
+}
+
+// exportHistory writes the entries in the given format
+func exportHistory(w io.Writer, entries []HistoryEntry, format string) error {
+	switch format {
+	case formatJSONL:
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(historyHeader{Schema: historySchema, Version: historyVersion}); err != nil {
+			return fmt.Errorf("error exporting history: %w", err)
+		}
+		for i := range entries {
+			if err := enc.Encode(&entries[i]); err != nil {
+				return fmt.Errorf("error exporting history: %w", err)
+			}
+		}
+		return nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write(historyCSVHeader)
+		for _, e := range entries {
+			_ = cw.Write([]string{e.Time.Format(time.RFC3339Nano), e.URL, e.Action, e.Source})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("error exporting history: %w", err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q (valid: %s, %s)", errHistoryFormat, format, formatJSONL, formatCSV)
+}
+
+// importJSONL reads an exported history in JSON lines, the header is
+// optional so plain history files can be imported too
+func importJSONL(b []byte) ([]HistoryEntry, error) {
+	first, _, _ := bytes.Cut(b, []byte("\n"))
+	var h historyHeader
+	if json.Unmarshal(first, &h) == nil && h.Schema == historySchema {
+		if h.Version > historyVersion {
+			return nil, fmt.Errorf("%w: %d, this version of %s supports up to %d",
+				errHistoryVersion, h.Version, appName, historyVersion)
+		}
+		b = b[len(first):]
+	}
+
+	return decodeHistory(bytes.NewReader(b))
+}
+
+// importCSV reads an exported history in CSV, columns are found by the
+// header names
+func importCSV(b []byte) ([]HistoryEntry, error) {
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error importing history: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int)
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	for _, name := range historyCSVHeader[:2] {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", errHistoryFormat, name)
+		}
+	}
+
+	field := func(r []string, name string) string {
+		if i, ok := col[name]; ok && i < len(r) {
+			return r[i]
+		}
+		return ""
+	}
+
+	entries := make([]HistoryEntry, 0, len(records)-1)
+	for _, r := range records[1:] {
+		t, err := time.Parse(time.RFC3339Nano, field(r, "time"))
+		if err != nil || field(r, "url") == "" {
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Time:   t,
+			URL:    field(r, "url"),
+			Action: field(r, "action"),
+			Source: field(r, "source"),
+		})
+	}
+
+	return entries, nil
+}
+
+// importHistory reads the entries of an exported history, the format is
+// detected from the content
+func importHistory(r io.Reader) ([]HistoryEntry, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error importing history: %w", err)
+	}
+
+	if t := bytes.TrimSpace(b); len(t) > 0 && t[0] == '{' {
+		return importJSONL(b)
+	}
+
+	return importCSV(b)
+}
+
+// mergeHistory adds the new entries that are not already in the history
+// and sorts the result by time
+func mergeHistory(entries, added []HistoryEntry) ([]HistoryEntry, int) {
+	key := func(e *HistoryEntry) string {
+		return e.Time.UTC().Format(time.RFC3339Nano) + "\x00" + e.URL + "\x00" + e.Action
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for i := range entries {
+		seen[key(&entries[i])] = true
+	}
+
+	var n int
+	for i := range added {
+		k := key(&added[i])
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		entries = append(entries, added[i])
+		n++
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, n
+}
+
+// writeHistory replaces the history file with the entries
+func writeHistory(entries []HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			f.Close()
+			return fmt.Errorf("error writing history: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+
+	return nil
+}
+
+// runHistoryExport writes the history to STDOUT
+func runHistoryExport(args []string) error {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	format := fs.String("format", formatJSONL, "output format: jsonl, csv")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("history export: %w", err)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := exportHistory(w, entries, *format); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error exporting history: %w", err)
+	}
+
+	return nil
+}
+
+// runHistoryImport merges exported histories from files or STDIN into the
+// history
+func runHistoryImport(args []string) error {
+	fs := flag.NewFlagSet("history import", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("history import: %w", err)
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var added []HistoryEntry
+	for _, path := range paths {
+		r, err := readFile(path)
+		if err != nil {
+			return err
+		}
+
+		e, err := importHistory(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		added = append(added, e...)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		return err
+	}
+
+	entries, n := mergeHistory(entries, added)
+	if n == 0 {
+		printInfo("no new history entries")
+		return nil
+	}
+
+	if err := writeHistory(entries); err != nil {
+		return err
+	}
+
+	printInfo(fmt.Sprintf("imported %d history entries", n))
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"0d", 0},
+		{"2w", 14 * 24 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"12h", 12 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"45s", 45 * time.Second},
+		{"1.5h", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSince(%q): got %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSinceInvalid(t *testing.T) {
+	for _, s := range []string{"", "d", "w", "7", "-1d", "-2h", "1.5d", "xd", "7 d", "3y", "1d2h", "99999999999w"} {
+		if d, err := parseSince(s); !errors.Is(err, errInvalidDuration) {
+			t.Errorf("parseSince(%q): got %v, %v, want %v", s, d, err, errInvalidDuration)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// hook names, the functions of the hooks script
+const (
+	hookOnMatch      = "on_match"
+	hookTransform    = "transform"
+	hookChooseAction = "choose_action"
+)
+
+// hookMaxSteps bounds the work of a hook call, so a runaway loop in the
+// script fails instead of hanging. Each call runs in its own thread, with
+// the whole budget.
+const hookMaxSteps = 10_000_000
+
+var errHook = errors.New("error running hooks")
+
+// hookScript is the hooks script, a Starlark file defining some of the
+// hooks as functions. Each hook gets the URL and, when it takes a second
+// parameter, the item with its type, source, line, count and action.
+type hookScript struct {
+	funcs map[string]*starlark.Function
+}
+
+// hooks is the loaded hooks script, nil without one
+var hooks *hookScript
+
+// newHookThread returns a thread to run the script in, with print going to
+// the log
+func newHookThread() *starlark.Thread {
+	return &starlark.Thread{
+		Name:  "hooks",
+		Print: func(_ *starlark.Thread, msg string) { log.Printf("hooks: %s", msg) },
+	}
+}
+
+// loadHooks runs the hooks script at path and keeps the hooks it defines.
+// Its globals are frozen, so the hooks can run at the same time.
+func loadHooks(path string) (*hookScript, error) {
+	globals, err := starlark.ExecFile(newHookThread(), path, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errHook, err)
+	}
+	globals.Freeze()
+
+	h := &hookScript{funcs: make(map[string]*starlark.Function)}
+	for _, name := range []string{hookOnMatch, hookTransform, hookChooseAction} {
+		v, ok := globals[name]
+		if !ok {
+			continue
+		}
+		fn, ok := v.(*starlark.Function)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is %s, not a function", errHook, name, v.Type())
+		}
+		h.funcs[name] = fn
+	}
+	log.Printf("hooks: %s defines %v", path, h.defined())
+
+	return h, nil
+}
+
+// defined returns the names of the hooks the script defines
+func (h *hookScript) defined() []string {
+	var names []string
+	for _, name := range []string{hookOnMatch, hookTransform, hookChooseAction} {
+		if h.funcs[name] != nil {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// has reports whether the script defines the hook
+func (h *hookScript) has(name string) bool {
+	return h != nil && h.funcs[name] != nil
+}
+
+// call runs the hook on the item, with the action given for choose_action
+func (h *hookScript) call(name string, m *Match, action string) (starlark.Value, error) {
+	fn := h.funcs[name]
+	args := starlark.Tuple{starlark.String(m.Value)}
+	if fn.NumParams() > 1 {
+		args = append(args, starlarkstruct.FromStringDict(starlark.String("item"), starlark.StringDict{
+			"url":    starlark.String(m.Value),
+			"type":   starlark.String(m.Type),
+			"source": starlark.String(m.Source),
+			"line":   starlark.MakeInt(m.Line),
+			"count":  starlark.MakeInt(m.Count),
+			"action": starlark.String(action),
+		}))
+	}
+
+	thread := newHookThread()
+	thread.SetMaxExecutionSteps(hookMaxSteps)
+	v, err := starlark.Call(thread, fn, args, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errHook, name, err)
+	}
+
+	return v, nil
+}
+
+// hookString returns the string returned by the hook, empty for None
+func hookString(name string, v starlark.Value) (string, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return "", nil
+	case starlark.String:
+		return string(v), nil
+	}
+
+	return "", fmt.Errorf("%w: %s returned %s, want a string or None", errHook, name, v.Type())
+}
+
+// transformHook replaces the URLs of the items with the ones returned by
+// the transform hook. The hook runs once for each distinct URL, with the
+// number of times it was found, and its result applies to all of them.
+func (h *hookScript) transformHook(items []Match) error {
+	counts := make(map[string]int)
+	for i := range items {
+		counts[items[i].Value]++
+	}
+
+	replaced := make(map[string]string, len(counts))
+	for i := range items {
+		value := items[i].Value
+		url, ok := replaced[value]
+		if !ok {
+			m := items[i]
+			m.Count = counts[value]
+			v, err := h.call(hookTransform, &m, "")
+			if err != nil {
+				return err
+			}
+			if url, err = hookString(hookTransform, v); err != nil {
+				return err
+			}
+			replaced[value] = url
+		}
+		if url != "" && url != value {
+			items[i].Value = url
+			items[i].Type = classify(url)
+		}
+	}
+
+	return nil
+}
+
+// matchHook returns the items kept by the on_match hook, which returns
+// False to drop an item and a number to give it that score
+func (h *hookScript) matchHook(items []Match) ([]Match, error) {
+	result := items[:0]
+	for i := range items {
+		m := items[i]
+		v, err := h.call(hookOnMatch, &m, "")
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case starlark.NoneType:
+		case starlark.Bool:
+			if !v {
+				continue
+			}
+		case starlark.Int:
+			score, ok := v.Int64()
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: score %s out of range", errHook, hookOnMatch, v)
+			}
+			m.Score = int(score)
+		default:
+			return nil, fmt.Errorf("%w: %s returned %s, want a bool, a number or None", errHook, hookOnMatch, v.Type())
+		}
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+// actionHook returns the action chosen by the choose_action hook for the
+// item, the one given when it chooses none
+func actionHook(name string, m *Match) string {
+	v, err := hooks.call(hookChooseAction, m, name)
+	if err == nil {
+		var action string
+		if action, err = hookString(hookChooseAction, v); err == nil && action != "" {
+			log.Printf("hooks: action %q for %s", action, m.Value)
+			return action
+		}
+	}
+	if err != nil {
+		log.Print(err)
+	}
+
+	return name
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testHooks loads the hooks script with the source given
+func testHooks(t *testing.T, src string) *hookScript {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks.star")
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := loadHooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+// testItems returns n items with distinct URLs
+func testItems(n int) []Match {
+	items := make([]Match, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, Match{Value: fmt.Sprintf("https://example.com/%d", i), Count: 1})
+	}
+
+	return items
+}
+
+func TestHooks(t *testing.T) {
+	h := testHooks(t, `
+def on_match(url, item):
+    if "drop" in url:
+        return False
+    if item.count > 1:
+        return 90
+    return None
+
+def transform(url, item):
+    if url.startswith("http://"):
+        return "https://" + url[len("http://"):]
+    return None
+`)
+
+	items := []Match{
+		{Value: "http://a.example/x"},
+		{Value: "https://drop.example/"},
+		{Value: "http://a.example/x"},
+	}
+	if err := h.transformHook(items); err != nil {
+		t.Fatal(err)
+	}
+	if got := values(items); !reflect.DeepEqual(got, []string{"https://a.example/x", "https://drop.example/", "https://a.example/x"}) {
+		t.Errorf("transform: got %q", got)
+	}
+
+	items = uniqueItems(items)
+	kept, err := h.matchHook(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 1 || kept[0].Value != "https://a.example/x" || kept[0].Score != 90 {
+		t.Errorf("on_match: got %+v, want the repeated URL with score 90", kept)
+	}
+}
+
+func TestHooksStepsPerCall(t *testing.T) {
+	// each call takes more than half of the budget
+	h := testHooks(t, `
+def on_match(url):
+    for i in range(1000000):
+        pass
+`)
+	if _, err := h.matchHook(testItems(2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHooksRunaway(t *testing.T) {
+	h := testHooks(t, `
+def on_match(url):
+    for i in range(1000000000):
+        pass
+`)
+	if _, err := h.matchHook(testItems(1)); !errors.Is(err, errHook) {
+		t.Fatalf("got %v, want the call cancelled", err)
+	}
+}
+
+func TestHooksConcurrent(t *testing.T) {
+	h := testHooks(t, `
+seen = {}
+
+def on_match(url):
+    return len(url)
+`)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.matchHook(testItems(100)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
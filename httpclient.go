@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// hostPool limits the number of concurrent requests to each host
+type hostPool struct {
+	sems  map[string]chan struct{}
+	mu    sync.Mutex
+	limit int
+}
+
+func newHostPool(limit int) *hostPool {
+	return &hostPool{sems: make(map[string]chan struct{}), limit: limit}
+}
+
+// acquire waits for a free slot for the host, or for the context to be
+// done, and returns the function that releases it
+func (p *hostPool) acquire(ctx context.Context, host string) (func(), error) {
+	p.mu.Lock()
+	sem, ok := p.sems[host]
+	if !ok {
+		sem = make(chan struct{}, p.limit)
+		p.sems[host] = sem
+	}
+	p.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-sem }) }, nil
+}
+
+// limitedTransport is a round tripper holding a slot of the host pool until
+// the body of the response is closed. The timeout starts once the slot is
+// acquired, so the time queued behind the other requests to the host does
+// not count.
+type limitedTransport struct {
+	base    http.RoundTripper
+	pool    *hostPool
+	timeout time.Duration
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.pool.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if t.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+		req = req.WithContext(ctx)
+		slot := release
+		release = func() {
+			cancel()
+			slot()
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseBody releases the slot of the host when closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// hosts is shared by the HTTP clients, so the limit applies across modes
+var hosts struct {
+	pool *hostPool
+	once sync.Once
+}
+
+// newHTTPClient returns a client limited to --per-host-concurrency requests
+// to the same host at a time, each request taking at most timeout from when
+// it is sent until its body is closed
+func newHTTPClient(timeout time.Duration) *http.Client {
+	hosts.once.Do(func() { hosts.pool = newHostPool(perHostFlag) })
+	return &http.Client{
+		Transport: &limitedTransport{base: http.DefaultTransport, pool: hosts.pool, timeout: timeout},
+	}
+}
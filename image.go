@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+)
+
+var errMissingCmd = errors.New("command not found in PATH")
+
+// readOCR returns a reader with the text recognized in the image
+func readOCR(path string) (io.Reader, error) {
+	const tesseract = "tesseract"
+	if _, err := exec.LookPath(tesseract); err != nil {
+		return nil, fmt.Errorf("error running OCR: %w: %s", errMissingCmd, tesseract)
+	}
+
+	output, err := exec.Command(tesseract, path, "stdout").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running OCR on %q: %w", path, err)
+	}
+
+	return bytes.NewReader(output), nil
+}
+
+// readQR returns a reader with the data decoded from the QR codes in the image
+func readQR(path string) (io.Reader, error) {
+	const zbarimg = "zbarimg"
+	if _, err := exec.LookPath(zbarimg); err != nil {
+		return nil, fmt.Errorf("error decoding QR: %w: %s", errMissingCmd, zbarimg)
+	}
+
+	output, err := exec.Command(zbarimg, "--quiet", "--raw", "-Sdisable", "-Sqrcode.enable", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding QR in %q: %w", path, err)
+	}
+
+	return bytes.NewReader(output), nil
+}
+
+// screenshotCmds are the commands tried to take a screenshot, the output file
+// is appended as the last argument
+var screenshotCmds = [][]string{
+	{"grim"},
+	{"maim"},
+	{"scrot", "--overwrite"},
+	{"import", "-window", "root"},
+	{"screencapture", "-x"},
+}
+
+// takeScreenshot saves a screenshot to a temporary file and returns its path
+func takeScreenshot() (string, error) {
+	f, err := os.CreateTemp("", appName+"-*.png")
+	if err != nil {
+		return "", fmt.Errorf("error creating screenshot file: %w", err)
+	}
+	f.Close()
+
+	for _, c := range screenshotCmds {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+
+		log.Printf("taking screenshot with %s", c[0])
+		args := append(c[1:len(c):len(c)], f.Name())
+		if err := exec.Command(c[0], args...).Run(); err != nil {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("error taking screenshot: %w", err)
+		}
+
+		return f.Name(), nil
+	}
+
+	os.Remove(f.Name())
+	return "", fmt.Errorf("error taking screenshot: %w: grim, maim, scrot, import or screencapture", errMissingCmd)
+}
+
+// readScreenshotQR takes a screenshot and decodes the QR codes in it
+func readScreenshotQR() (io.Reader, error) {
+	path, err := takeScreenshot()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	return readQR(path)
+}
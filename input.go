@@ -0,0 +1,416 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"golang.org/x/term"
+)
+
+// source is a named input
+type source struct {
+	r    io.Reader
+	name string
+}
+
+// stdinIsTTY reports whether stdin is attached to a terminal, and not to
+// another device like /dev/null
+func stdinIsTTY() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// stdinPiped reports whether stdin is a pipe or a file, and not a terminal
+// or /dev/null like when run from a hotkey
+func stdinPiped() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice == 0
+}
+
+// readClipboard returns a reader with the clipboard content
+func readClipboard() (io.Reader, error) {
+	var s string
+	var err error
+	if len(platform.Paste) > 0 {
+		s, err = pasteCmd()
+	} else {
+		s, err = clipboard.ReadAll()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading clipboard: %w", err)
+	}
+
+	return strings.NewReader(s), nil
+}
+
+// readPrimary returns a reader with the X primary selection, the text
+// selected with the mouse
+func readPrimary() (io.Reader, error) {
+	var args []string
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		args = []string{"wl-paste", "--primary", "--no-newline"}
+	case os.Getenv("DISPLAY") != "":
+		args = []string{"xclip", "-o", "-selection", "primary"}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			args = []string{"xsel", "--primary", "--output"}
+		}
+	default:
+		return nil, fmt.Errorf("error reading primary selection: %w", errors.New("no X or Wayland display"))
+	}
+
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading primary selection: %s: %w", args[0], err)
+	}
+
+	return bytes.NewReader(output), nil
+}
+
+// readTmuxPane returns a reader with the visible content of the current tmux pane
+func readTmuxPane() (io.Reader, error) {
+	if os.Getenv("TMUX") == "" {
+		return nil, fmt.Errorf("error reading tmux pane: %w", errors.New("not running inside tmux"))
+	}
+
+	output, err := exec.Command("tmux", "capture-pane", "-p", "-J").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error reading tmux pane: %w", err)
+	}
+
+	return bytes.NewReader(output), nil
+}
+
+// mmapMinSize is the size from which the files are mapped in memory instead
+// of being read, smaller ones are faster to read
+const mmapMinSize = 1 << 20
+
+// readFile returns a reader with the content of the file, "-" reads from
+// stdin. Large files are mapped in memory, the file is closed on return
+// in both cases.
+func readFile(path string) (io.Reader, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() >= mmapMinSize {
+		if r, err := mapFile(f, fi.Size()); err == nil {
+			return r, nil
+		}
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// tarMagic is found at offset 257 of a tar header
+var tarMagic = []byte("ustar")
+
+const tarMagicOffset = 257
+
+// zipMagic is the signature of a zip local file header
+var zipMagic = []byte("PK\x03\x04")
+
+// prepareInput decompresses the input and converts it to text according to
+// its content type, archives are expanded into a source for each member
+func (o *Options) prepareInput(name string, r io.Reader) ([]source, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(tarMagicOffset + len(tarMagic))
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return o.readZip(name, br)
+	case len(head) == tarMagicOffset+len(tarMagic) && bytes.Equal(head[tarMagicOffset:], tarMagic):
+		return o.readTar(name, br)
+	}
+
+	r, err = o.convertInput(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return []source{{name: name, r: r}}, nil
+}
+
+// memberName returns the name used for a member of an archive
+func memberName(archive, member string) string {
+	return archive + "::" + member
+}
+
+// includeMember reports whether the archive member matches the --include
+// and --exclude patterns, they match the full path or the base name
+func (o *Options) includeMember(name string) bool {
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+			if ok, _ := path.Match(p, path.Base(name)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if match(o.Exclude) {
+		return false
+	}
+
+	return len(o.Include) == 0 || match(o.Include)
+}
+
+// readArchiveMember prepares an archive member as a source
+func (o *Options) readArchiveMember(archive, member string, r io.Reader) ([]source, error) {
+	name := memberName(archive, member)
+	log.Printf("scanning %s", name)
+
+	// read it now, the member reader is only valid until the next one
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+
+	return o.prepareInput(name, bytes.NewReader(b))
+}
+
+// readTar returns a source for each regular file in the tar archive
+func (o *Options) readTar(name string, r io.Reader) ([]source, error) {
+	var sources []source
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return sources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading tar %s: %w", name, err)
+		}
+
+		if h.Typeflag != tar.TypeReg || !o.includeMember(h.Name) {
+			continue
+		}
+
+		s, err := o.readArchiveMember(name, h.Name, tr)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s...)
+	}
+}
+
+// readZip returns a source for each regular file in the zip archive
+func (o *Options) readZip(name string, r io.Reader) ([]source, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading zip %s: %w", name, err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading zip %s: %w", name, err)
+	}
+
+	var sources []source
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || !o.includeMember(f.Name) {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error reading zip %s: %w", name, err)
+		}
+
+		s, err := o.readArchiveMember(name, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s...)
+	}
+
+	return sources, nil
+}
+
+// walkDir returns the regular files in the directory tree, skipping hidden
+// directories like .git
+func walkDir(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %w", err)
+	}
+
+	return files, nil
+}
+
+// inputPaths returns the files to read, expanding the directories with
+// --recursive
+func (o *Options) inputPaths(args []string) ([]string, error) {
+	if !o.Recursive {
+		return args, nil
+	}
+
+	var paths []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err != nil || !fi.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		files, err := walkDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, files...)
+	}
+
+	return paths, nil
+}
+
+// inputSources returns the selected input sources, falling back to stdin
+// when no source is given. Files given that are all skipped, like the ones
+// of an empty directory with -R, give no sources.
+func (o *Options) inputSources() ([]source, error) {
+	var sources []source
+	add := func(name string, r io.Reader, err error) error {
+		if err != nil {
+			return err
+		}
+		s, err := o.prepareInput(name, r)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, s...)
+		return nil
+	}
+
+	if clipboardFlag {
+		r, err := readClipboard()
+		if err := add("clipboard", r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if tmuxFlag {
+		r, err := readTmuxPane()
+		if err := add("tmux", r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if fromDaemonFlag {
+		r, err := readDaemon()
+		if err := add("daemon", r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if ocrFlag != "" {
+		r, err := readOCR(ocrFlag)
+		if err := add(ocrFlag, r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if qrFlag != "" {
+		r, err := readQR(qrFlag)
+		if err := add(qrFlag, r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if screenshotFlag {
+		r, err := readScreenshotQR()
+		if err := add("screenshot", r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range fromPluginFlag {
+		r, err := readPluginSource(name)
+		if err := add(name, r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	paths, err := o.inputPaths(flag.Args())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		r, err := readFile(path)
+		name := path
+		if path == "-" {
+			name = "stdin"
+		}
+		if err := add(name, r, err); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sources) > 0 || len(paths) > 0 || flag.NArg() > 0 {
+		return sources, nil
+	}
+
+	if primaryFlag && !stdinPiped() {
+		r, err := readPrimary()
+		if err := add("primary", r, err); err != nil {
+			return nil, err
+		}
+		return sources, nil
+	}
+
+	if stdinIsTTY() {
+		return nil, fmt.Errorf(
+			"%w\n\npipe some text, e.g. 'cat file | %s', or use an input source:\n"+
+				"  %s file ...\n  %s --from-clipboard\n  %s --tmux\n  %s --ocr image.png\n\nTry '%s -h' for more information",
+			errNoInput, appName, appName, appName, appName, appName, appName,
+		)
+	}
+
+	return o.prepareInput("stdin", os.Stdin)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// interactivePage is the number of items listed at once
+const interactivePage = 30
+
+var errSelection = errors.New("invalid selection")
+
+const interactiveHelp = `commands:
+  list [page]          list the items, 30 per page
+  filter <text>        keep the items containing text (grep)
+  exclude <text>       drop the items containing text
+  where <expr>         keep the items matching a --where expression
+  type <type ...>      keep the items of the types
+  sort freq|alpha|host|line
+                       sort the items, freq puts the most found first
+  open <sel>           open the items, sel is all, 3, 1,4 or 2-5
+  copy <sel>           copy the items to the clipboard
+  do <action> <sel>    run the action on the items
+  undo                 go back to the items before the last command
+  reset                go back to all the items
+  count                show the number of items
+  help                 show this message
+  quit                 leave, also Ctrl-D`
+
+// session is the state of the interactive mode, the filters and sorts are
+// applied to the current items and kept in history for undo
+type session struct {
+	opts    *Options
+	all     []Match
+	items   []Match
+	history [][]Match
+	out     io.Writer
+}
+
+// push replaces the current items, keeping them for undo
+func (s *session) push(items []Match) {
+	s.history = append(s.history, s.items)
+	s.items = items
+	fmt.Fprintf(s.out, "%d items\n", len(items))
+}
+
+// keep returns a copy of the current items for which fn is true
+func (s *session) keep(fn func(m *Match) bool) []Match {
+	var result []Match
+	for i := range s.items {
+		if fn(&s.items[i]) {
+			result = append(result, s.items[i])
+		}
+	}
+
+	return result
+}
+
+// list prints the page of the current items, numbered from 1
+func (s *session) list(page int) {
+	start := (page - 1) * interactivePage
+	if start >= len(s.items) {
+		fmt.Fprintln(s.out, "no items")
+		return
+	}
+	end := min(start+interactivePage, len(s.items))
+	for i := start; i < end; i++ {
+		fmt.Fprintf(s.out, "%4d  %s\n", i+1, s.opts.formatItem(&s.items[i], i, false))
+	}
+	if pages := (len(s.items) + interactivePage - 1) / interactivePage; pages > 1 {
+		fmt.Fprintf(s.out, "page %d of %d, 'list %d' for the next\n", page, pages, page+1)
+	}
+}
+
+// selection returns the items of the selection: all, a number, or numbers
+// and ranges separated by commas like 1,4-6
+func (s *session) selection(sel string) ([]Match, error) {
+	if sel == "all" {
+		return s.items, nil
+	}
+
+	var selected []Match
+	for _, part := range strings.Split(sel, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(from)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(to)
+		}
+		if err != nil || first < 1 || last < first || last > len(s.items) {
+			return nil, fmt.Errorf("%w: %q, there are %d items", errSelection, part, len(s.items))
+		}
+		selected = append(selected, s.items[first-1:last]...)
+	}
+
+	return selected, nil
+}
+
+// run runs the action on the selection
+func (s *session) run(name, sel string) error {
+	selected, err := s.selection(sel)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return errNoURLFound
+	}
+
+	action, err := lookupAction(name)
+	if err != nil {
+		return err
+	}
+	if !allowsMulti(action) && len(selected) > 1 {
+		return fmt.Errorf("%w: %q runs on one item", errSelection, name)
+	}
+	selected = s.opts.schemeItems(selected)
+	if err := action.Run(context.Background(), s.opts, selected); err != nil {
+		return err
+	}
+	for i := range selected {
+		addHistory(&selected[i], name)
+	}
+
+	return nil
+}
+
+// sortItems sorts the current items by the key
+func (s *session) sortItems(key string) error {
+	items := append([]Match(nil), s.items...)
+	var less func(a, b *Match) bool
+	switch key {
+	case "freq":
+		less = func(a, b *Match) bool { return a.Count > b.Count }
+	case "alpha":
+		less = func(a, b *Match) bool { return a.Value < b.Value }
+	case "host":
+		less = func(a, b *Match) bool { return itemHost(a.Value) < itemHost(b.Value) }
+	case "line":
+		less = func(a, b *Match) bool {
+			if a.Source != b.Source {
+				return a.Source < b.Source
+			}
+			return a.Line < b.Line
+		}
+	default:
+		return fmt.Errorf("%w: sort %q (valid: freq, alpha, host, line)", errInvalidFlag, key)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(&items[i], &items[j]) })
+	s.push(items)
+
+	return nil
+}
+
+// itemHost returns the lowercased host of the URL, or of the email
+func itemHost(s string) string {
+	if u, err := parseURL(s); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	_, host, _ := strings.Cut(s, "@")
+
+	return strings.ToLower(host)
+}
+
+// exec runs the command line, it returns false to leave
+func (s *session) exec(line string) (bool, error) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch cmd {
+	case "":
+	case "quit", "exit", "q":
+		return false, nil
+	case "help", "?":
+		fmt.Fprintln(s.out, interactiveHelp)
+	case "list", "ls", "l":
+		page := 1
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return true, fmt.Errorf("%w: page %q", errSelection, arg)
+			}
+			page = n
+		}
+		s.list(page)
+	case "count":
+		fmt.Fprintf(s.out, "%d items\n", len(s.items))
+	case "filter", "grep", "exclude":
+		if arg == "" {
+			return true, fmt.Errorf("%w: %s needs a text", errInvalidFlag, cmd)
+		}
+		text := strings.ToLower(arg)
+		keep := cmd != "exclude"
+		s.push(s.keep(func(m *Match) bool {
+			return strings.Contains(strings.ToLower(m.Value), text) == keep
+		}))
+	case "where":
+		e, err := parseWhere(arg)
+		if err != nil {
+			return true, err
+		}
+		if e == nil {
+			return true, fmt.Errorf("%w: where needs an expression", errWhere)
+		}
+		s.push(s.keep(func(m *Match) bool { return e.eval(&whereItem{m: m}) }))
+	case "type":
+		types := strings.Fields(strings.ReplaceAll(arg, ",", " "))
+		s.push(s.keep(func(m *Match) bool { return inList(types, m.Type) }))
+	case "sort":
+		return true, s.sortItems(arg)
+	case "open", "o":
+		return true, s.run(actionOpen, arg)
+	case "copy", "c":
+		return true, s.run(actionCopy, arg)
+	case "do":
+		name, sel, _ := strings.Cut(arg, " ")
+		return true, s.run(name, strings.TrimSpace(sel))
+	case "undo":
+		if len(s.history) == 0 {
+			return true, fmt.Errorf("%w: nothing to undo", errSelection)
+		}
+		s.items = s.history[len(s.history)-1]
+		s.history = s.history[:len(s.history)-1]
+		fmt.Fprintf(s.out, "%d items\n", len(s.items))
+	case "reset":
+		s.push(s.all)
+	default:
+		return true, fmt.Errorf("unknown command %q, 'help' lists them", cmd)
+	}
+
+	return true, nil
+}
+
+// runInteractive reads commands operating on the items until quit or the
+// end of the input. Commands are read from the terminal when the items
+// came from STDIN.
+func runInteractive(o *Options, items []Match) error {
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stdout
+	if flag.NArg() == 0 && stdinPiped() {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("--interactive needs a terminal: %w", err)
+		}
+		defer tty.Close()
+		in, out = tty, tty
+	}
+
+	s := &session{opts: o, all: items, items: items, out: out}
+	fmt.Fprintf(out, "%d items, 'help' lists the commands\n", len(items))
+	prompt := appName + "> "
+	if promptFlag != "" {
+		prompt = promptFlag + " "
+	}
+
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, prompt)
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return sc.Err()
+		}
+		more, err := s.exec(sc.Text())
+		if err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+		}
+		if !more {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const actionLater = "later"
+
+var errLaterCommand = errors.New("unknown later command")
+
+// LaterEntry is an item of the reading list
+type LaterEntry struct {
+	Added time.Time  `json:"added"`
+	Done  *time.Time `json:"done,omitempty"`
+	URL   string     `json:"url"`
+	Title string     `json:"title,omitempty"`
+}
+
+// laterPath returns the path of the reading list
+func laterPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "later.json"), nil
+}
+
+// readLater returns the entries of the reading list
+func readLater() ([]LaterEntry, error) {
+	path, err := laterPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading reading list: %w", err)
+	}
+
+	var entries []LaterEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("error reading reading list: %w", err)
+	}
+
+	return entries, nil
+}
+
+// writeLater saves the reading list
+func writeLater(entries []LaterEntry) error {
+	path, err := laterPath()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing reading list: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("error writing reading list: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing reading list: %w", err)
+	}
+
+	return nil
+}
+
+// addLater adds the items to the reading list, items already pending are
+// skipped and done ones are added again
+func addLater(items []Match) (int, error) {
+	entries, err := readLater()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e.Done == nil {
+			pending[e.URL] = true
+		}
+	}
+
+	var n int
+	for _, m := range items {
+		if pending[m.Value] {
+			continue
+		}
+		pending[m.Value] = true
+		entries = append(entries, LaterEntry{URL: m.Value, Title: m.Label, Added: time.Now()})
+		n++
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, writeLater(entries)
+}
+
+// markLaterDone marks the pending entries with the URLs as done
+func markLaterDone(urls []string) (int, error) {
+	entries, err := readLater()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var n int
+	for i := range entries {
+		if entries[i].Done == nil && inList(urls, entries[i].URL) {
+			entries[i].Done = &now
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, writeLater(entries)
+}
+
+// pendingLater returns the pending entries as items, newest first
+func pendingLater() ([]Match, error) {
+	entries, err := readLater()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Match
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Done != nil {
+			continue
+		}
+		m := Match{Value: e.URL, Source: actionLater, Type: classify(e.URL)}
+		if e.Title != "" {
+			m.Label = e.Title + "  " + e.URL
+		}
+		items = append(items, m)
+	}
+
+	return items, nil
+}
+
+// laterAction adds the selected items to the reading list
+type laterAction struct{}
+
+func (laterAction) Name() string { return actionLater }
+
+func (laterAction) Run(_ context.Context, _ *Options, items []Match) error {
+	n, err := addLater(items)
+	if err != nil {
+		return err
+	}
+
+	notify(fmt.Sprintf("%d item(s) added to reading list", n))
+	return nil
+}
+
+func init() {
+	registerAction(laterAction{})
+}
+
+// runLater runs the later subcommand
+func runLater(o *Options, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "add":
+		return runLaterAdd(o, args[1:])
+	case "list":
+		return runLaterList(args[1:])
+	case "open":
+		return runLaterOpen(o)
+	case "done":
+		return runLaterDone(o, args[1:])
+	}
+
+	return fmt.Errorf("%w: %q (valid: add, list, open, done)", errLaterCommand, args[0])
+}
+
+// runLaterAdd adds the URLs given as arguments, or found in STDIN
+func runLaterAdd(o *Options, args []string) error {
+	var items []Match
+	for _, url := range args {
+		items = append(items, Match{Value: url})
+	}
+
+	if len(items) == 0 {
+		if stdinIsTTY() {
+			return fmt.Errorf("%w\n\nUsage: %s later add [url ...], or pipe some text, e.g. 'cat file | %s later add'",
+				errNoInput, appName, appName)
+		}
+		// the arguments of the command line are the subcommand's, not files
+		sources, err := o.prepareInput("stdin", os.Stdin)
+		if err != nil {
+			return err
+		}
+		if items, err = o.extractItems(sources, nil); err != nil {
+			return err
+		}
+		items = uniqueItems(items)
+	}
+
+	n, err := addLater(items)
+	if err != nil {
+		return err
+	}
+
+	printInfo(fmt.Sprintf("%d item(s) added to reading list", n))
+	return nil
+}
+
+// runLaterList prints the pending entries, or all of them with --all
+func runLaterList(args []string) error {
+	fs := flag.NewFlagSet("later list", flag.ExitOnError)
+	all := fs.Bool("all", false, "include done entries")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("later list: %w", err)
+	}
+
+	entries, err := readLater()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Done != nil && !*all {
+			continue
+		}
+
+		line := e.URL
+		if e.Title != "" {
+			line += "\t" + e.Title
+		}
+		if e.Done != nil {
+			line = "[done] " + line
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// runLaterOpen shows the pending entries in the menu, opens the selected
+// ones and marks them as done
+func runLaterOpen(o *Options) error {
+	items, err := pendingLater()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		printInfo("reading list is empty")
+		return nil
+	}
+
+	menu.addArgs()
+	menu.prompt("Later>")
+	menu.Arguments = append(menu.Arguments, menu.MultiArgs...)
+	selected, ok := selectURL(o, items)
+	if !ok {
+		return nil
+	}
+
+	urls := make([]string, 0, len(selected))
+	for i := range selected {
+		if err := (openAction{}).Run(context.Background(), o, selected[i:i+1]); err != nil {
+			return err
+		}
+		urls = append(urls, selected[i].Value)
+	}
+
+	_, err = markLaterDone(urls)
+	return err
+}
+
+// runLaterDone marks the URLs given as arguments as done, without
+// arguments the entries are selected in the menu
+func runLaterDone(o *Options, args []string) error {
+	urls := args
+	if len(urls) == 0 {
+		items, err := pendingLater()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			printInfo("reading list is empty")
+			return nil
+		}
+
+		menu.addArgs()
+		menu.prompt("Done>")
+		menu.Arguments = append(menu.Arguments, menu.MultiArgs...)
+		selected, ok := selectURL(o, items)
+		if !ok {
+			return nil
+		}
+		for _, m := range selected {
+			urls = append(urls, m.Value)
+		}
+	}
+
+	n, err := markLaterDone(urls)
+	if err != nil {
+		return err
+	}
+
+	printInfo(fmt.Sprintf("%d item(s) marked as done", n))
+	return nil
+}
@@ -5,6 +5,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -14,32 +16,95 @@ import (
 	"os/exec"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/atotto/clipboard"
+	"github.com/haaag/GoURL/extract"
 )
 
-const (
-	urlRegex   = `(((http|https|gopher|gemini|ftp|ftps|git)://|www\.)[a-zA-Z0-9.]*[:;a-zA-Z0-9./+@$&%?$\#=_~-]*)`
-	emailRegex = `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`
-)
+const urlRegex = extract.URLPattern
 
 var (
-	appName       = "gourl"
-	appVersion    = "0.1.1"
-	errNoURLFound = errors.New("no urls found")
+	appName        = "gourl"
+	appVersion     = "0.1.1"
+	errNoURLFound  = errors.New("no urls found")
+	errNoInput     = errors.New("no input: stdin is a terminal")
+	errInvalidFlag = errors.New("invalid flag value")
+	errHTTPStatus  = errors.New("unexpected status")
 )
 
 var (
-	customRegexFlag string
 	copyFlag        bool
 	openFlag        bool
-	limitFlag       int
-	indexFlag       bool
+	checkFlag       bool
+	checkDNSFlag    bool
+	retriesFlag     int
+	backoffFlag     time.Duration
+	actTimeoutFlag  time.Duration
+	failOnFlag      string
+	perHostFlag     int
+	snapshotFlag    string
 	menuArgsFlag    string
 	verboseFlag     bool
 	xdgOpen         string
 	versionFlag     bool
+	jsonFlag        bool
+	clipboardFlag   bool
+	tmuxFlag        bool
+	ocrFlag         string
+	qrFlag          string
+	screenshotFlag  bool
+	fromPluginFlag  listFlag
+	hooksFlag       string
+	dataURIFlag     string
+	timelineFlag    bool
+	benchFlag       bool
+	metricsFlag     string
+	webhookFlag     string
+	webhookTmplFlag string
+	interactiveFlag bool
+	pageSizeFlag    int
+	modeFlag        string
+	selJSONFlag     bool
+	fallbackFlag    bool
+	debugMenuFlag   bool
+	forgeFlag       bool
+	forgeAPIFlag    bool
+	playFlag        bool
+	playerFlag      string
+	mediaInfoFlag   bool
+	composeFlag     string
+	historyFlag     bool
+	noHistoryFlag   bool
+	daemonFlag      bool
+	sendFlag        bool
+	fromDaemonFlag  bool
+	daemonSizeFlag  int
+	socketFlag      string
+	portalFlag      string
+	actionFlag      string
+	configFlag      string
+	askActionFlag   bool
+	laterFlag       bool
+	readFlag        bool
+	readOutputFlag  string
+	peekFlag        bool
+	decodeFlag      bool
+	promptFlag      string
+	noAutoSelFlag   bool
+	firstFlag       bool
+	lastFlag        bool
+	selectFlag      int
+	previewFlag     string
+	primaryFlag     bool
+	transformFlag   bool
+	intersectFlag   bool
+	unionFlag       bool
 )
 
 func printUsage() {
@@ -47,23 +112,193 @@ func printUsage() {
 Extract URLs from STDIN
 
 Usage: 
-  %s [options]
+  %s [options] [file ...]
+  %s history [--search text] [--since 7d] [--domain host] [--json]
+  %s history export [--format jsonl|csv]
+  %s history import [file ...]
+  %s later [add [url ...] | list [--all] | open | done [url ...]]
+  %s test-regex [-E pattern [-F] [--regex-flags flags]] [--explain] [file ...]
+  %s selftest [-v] [--write dir] [case ...]
+  %s config [check | show]
+  %s diff [--json] old new
+  %s clipwatch [watch [--interval 1s] [--size 200] | list | pick]
+  %s plugins
+  %s <plugin> [args ...]
 
 Options:
   -c, --copy        Copy to clipboard
-  -o, --open        Open with xdg-open
+  -o, --open        Open with $BROWSER or xdg-open
+  --opener          Command used to open URLs, 'portal' or 'gio' (default $BROWSER or
+                    xdg-open)
+  --portal          Use the desktop portal: never, auto, always (default auto)
+  -p, --play        Play with media player
+  --later           Add the selection to the reading list
+  --read            Show the article of the selected page in $PAGER, as Markdown
+  --read-output     Write the article shown by --read to a file
+  --first           Run the action on the first item found, without the menu
+  --last            Run the action on the last item found, without the menu
+  --select          Run the action on the Nth item, as numbered by --index, negative
+                    numbers count from the end
+  --no-auto-select  Show the menu even when a single item is found, by default it
+                    is selected when there is an action
+  --ask-action      Choose the action from a menu after selecting the URL
+  --peek            Show status, type, size and redirects of the selected URLs
+                    and ask before running the action
+  --print-selection-json
+                    Print the selected items as JSON lines (url, index, type,
+                    label, source, line, count) instead of running an action,
+                    for wrapper scripts
+  -A, --action      Run action on the selection: copy, open, play, later, read,
+                    snapshot, compose, print, custom (menu with the actions in
+                    config), a config action or a plugin
+  --action-timeout  Wait for the actions and openers to finish, killing them
+                    after the duration, e.g. 30s (default 0, leave them running)
+  --player          Media player used by --play (default mpv)
+  --compose-cmd     Mail client used to open emails (default $MAILER or xdg-email)
+  --verify-email    Remove emails whose domain has no MX or A records
+  --preview         Show images of the links in the builtin menu: none, auto,
+                    kitty, sixel (default none)
+  --media-info      Show title and duration of media links in the menu (yt-dlp)
   -E, --regex       Custom regex search
+  -F, --fixed-string
+                    Match the -E pattern as a literal string
+  --multiline       Run -E over the whole input of each source, so matches can
+                    span lines, and join the URLs broken by hard wraps
+  --engine          Regex engine of -E: re2 (default) or pcre, which supports
+                    lookarounds and backreferences (needs perl)
+  --matcher         fast (default) skips the lines that cannot have a match, compat
+                    runs the regexes on every line
+  --bench           Scan the input with both matchers, reporting their speed and
+                    the items found by only one of them
+  --regex-flags     Flags of the -E pattern: i (case-insensitive), m (multiline
+                    ^ and $), s (. matches newline), U (ungreedy), e.g. i,s
+  --prefix          Add text before each -E match, e.g. https://tracker/id/
+  --suffix          Add text after each -E match
+  --capture-window  Show N characters of context around each -E match, as a
+                    separate field in the output and the menu
+  --refang          Find defanged URLs and domains like hxxps://evil[.]com
+  --defang          Defang the URLs printed or copied, like hxxps://evil[.]com
+  --decode-embedded
+                    Also find the URLs encoded in base64 inside the URLs, like in
+                    tracking links and SSO redirects (?url=aHR0cHM6...)
   -l, --limit       Limit number of items
+  --reverse         List the items from the last found to the first
+  --min-count       Only items found at least this many times (default 1)
+  --min-length      Only items at least N characters long, drops junk like www.a
+  --max-length      Only items at most N characters long, drops blobs
+  --min-confidence  Only items with a confidence score of at least this, 0-100
+  --show-score      Show the confidence score of each item
   -i, --index       Add index to URLs found
+  --decode-display  Show percent-decoded URLs in the menu, actions get them encoded
+  --default-scheme  Scheme added to the www. links given to the actions: https or
+                    http (default https)
+  --normalize       Also add it in the list output, where www. links are shown
+                    as found by default
+  --clean           Remove tracking parameters like utm_source and fbclid
+  --unwrap          Replace redirector links (Google /url?q=, Outlook safelinks,
+                    Facebook l.php) with their destination, without requests
+  --expand          Replace short links with the URL they redirect to
+  --canonicalize-remote
+                    Replace page URLs with the canonical URL the page declares
+                    (rel=canonical or og:url), merging AMP and mobile variants
+  --transform       Rewrite the URLs given one per line, without extracting them,
+                    with --clean, --unwrap, --expand, --canonicalize-remote,
+                    --punycode, --percent-encode, --refang and --defang
+  --punycode        Encode internationalized domains with punycode (xn--...)
+  --percent-encode  Percent-encode the non-ASCII characters of the URLs
   -a, --args        Args for dmenu
-  -V, --version     Output version information
+  --prompt          Prompt of the menu
+  --menu-fallback   Use the builtin menu when the menu command is not found
+  --debug-menu      Print the menu command line, environment and exit status
+  --page-size       Show the items in the menu in pages of N, with next, previous
+                    and show all entries, for menus slow with huge lists
+  --hooks           Starlark script customizing the items with on_match, transform
+                    and choose_action functions
+  --scheme-allow    Only extract URLs with these schemes, e.g. https,mailto
+  --scheme-deny     Do not extract URLs with these schemes, e.g. gopher,gemini,ftp
+  --only-type       Only items of type: web, email, image, video, audio,
+                    doc, archive, repo, tracker
+  --where           Only items matching an expression on the URL components,
+                    e.g. 'host ~= "github.com" && path ^= "/issues/"'
+  --prefer-domains  List first the items of the domains in file, one per line,
+                    subdomains included, like the company git and tickets
+  --enrich-forge    Show GitHub/GitLab/Codeberg links as labels in the menu
+  --forge-api       Fetch titles for --enrich-forge from the forge API
+  --intersect       Print the URLs found in all the files, marking the files with x
+  --union           Print the URLs found in any of the files, marking the files
+                    where each one is found with x
+  --interactive     Explore the items at a prompt with commands like 'filter github',
+                    'sort freq', 'open 3' and 'copy all' ('help' lists them)
+  --timeline        Show when the URLs of timestamped log lines were found, with
+                    their count and a sparkline of their occurrences over time
+  --with-source     Show where each item was found (source:line)
+  --output-format   Output format: text, csv, tsv, sarif, github (default text),
+                    sarif and github report the broken links found by --check
+  --check           Check the URLs found and report the broken ones
+  --check-dns       Like --check, only resolving the hosts without HTTP requests
+  --retries         Retries of transient failures in check mode (default 2)
+  --retry-backoff   Delay before the first retry, doubled each time (default 1s)
+  --fail-on         Exit with error on: broken (permanent failures), all, none
+                    (default broken)
+  --webhook         Post the links broken since the last check with the same
+                    arguments, and the new URLs found, to the URL as JSON (Slack
+                    and Discord messages for their webhooks). Also the new URLs
+                    copied in 'clipwatch'
+  --webhook-template
+                    Go template of the webhook payload, with .Event, .Text and
+                    .Items (url, status, source, line) and a json function
+  --metrics         Write counters of the run (URLs extracted, links checked and
+                    broken, check duration) to file in the OpenMetrics format
+  --extract-data-uris
+                    Decode the data: URIs found, like inline images in HTML, to
+                    files in dir and print their paths
+  --snapshot        Save the response of each URL to dir, with an index.json
+                    manifest (with -A snapshot only the selected ones)
+  --per-host-concurrency
+                    Concurrent requests to the same host (default 2)
+  --from-clipboard  Read input from clipboard
+  --primary-in      Read input from the X primary selection when there is no STDIN
+  --tmux            Read input from current tmux pane
+  --ocr             Read input from text in image (tesseract)
+  --qr-decode       Read input from QR codes in image (zbarimg)
+  --screenshot      Read input from QR codes in a screenshot
+  --from-plugin     Read input from the output of the gourl-<name> plugins
+  --input           Input type: auto, text, html, json, pdf, mbox, binary
+  --encoding        Input encoding: auto, utf-8, utf-16le, utf-16be, latin1, windows-1252
+  --binary          Scan printable strings in binary files (--input binary)
+  --min-run         Minimum length of strings in binary mode (default 6)
+  --max-line-bytes  Split lines longer than this (default 1048576)
+  --lines           Only scan these lines of each input, e.g. 100:500, 100: or :500
+  --after-match     Only scan the lines after the first one matching regex
+  --before-match    Only scan the lines before the first one matching regex
+  --head            Only scan the first N lines of each input
+  --tail            Only scan the last N lines of each input, e.g. the newest in
+                    logs or tmux scrollback
+  --include         Only scan archive members matching pattern
+  --exclude         Skip archive members matching pattern
+  -R, --recursive   Read the files in directories given as arguments
+  --daemon          Keep recent URLs sent with --send, listening on a socket
+  --send            Send input to the daemon
+  --from-daemon     Read input from the URLs kept by the daemon
+  --daemon-size     Number of URLs kept by the daemon (default 500)
+  --socket          Path of the daemon socket
+  --history         Save the selection to the history, off by default
+  --no-history      Do not save the selection, even with history in the config
+  --config          Path of the config file
+  --mode            Apply the flags of a mode of the config, e.g. tmux-open
+  -V, --version     Output version and build information
+  --json            Output version information as JSON, with --version
   -v, --verbose     Verbose mode
   -h, --help        Show this message
-`, version(), appName)
+`, version(), appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName, appName)
 }
 
 // logErrAndExit logs the error and exits the program
 func logErrAndExit(err error) {
+	if errors.Is(err, syscall.EPIPE) {
+		// the reader of the output is gone, like head after its lines
+		os.Exit(0)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %s\n", appName, err)
 		os.Exit(1)
@@ -91,16 +326,23 @@ func setVerboseLevel() {
 	log.SetOutput(silentLogger.Writer())
 }
 
-// newRegexMatcherWithPrefix creates a regex function
+// newRegexMatcherWithPrefix creates a regex function. The lines without a
+// match cost no allocation, the ones with matches one for the result.
 func newRegexMatcherWithPrefix(regex, prefix string) func(string) []string {
 	re := regexp.MustCompile(regex)
 	return func(line string) []string {
-		matches := re.FindAllString(line, -1)
-		urls := make([]string, 0)
-		for _, match := range matches {
-			url := strings.Split(match, " ")[0]
+		spans := re.FindAllStringIndex(line, -1)
+		if len(spans) == 0 {
+			return nil
+		}
+		urls := make([]string, 0, len(spans))
+		for _, sp := range spans {
+			url := line[sp[0]:sp[1]]
+			if i := strings.IndexByte(url, ' '); i >= 0 {
+				url = url[:i]
+			}
 			if prefix != "" {
-				url = fmt.Sprintf("%s%s", prefix, url)
+				url = prefix + url
 			}
 			urls = append(urls, url)
 		}
@@ -108,42 +350,187 @@ func newRegexMatcherWithPrefix(regex, prefix string) func(string) []string {
 	}
 }
 
-func removeIdx(s string) string {
-	if !indexFlag {
-		return s
+// newURLFinder returns the finder of URLs, without the punctuation and
+// parentheses around them and with their scheme in lowercase
+func (o *Options) newURLFinder() func(string) []string {
+	find := newRegexMatcherWithPrefix(o.urlPattern(), "")
+	return o.withPrefilter(extract.MayContainURL, func(line string) []string {
+		found := find(line)
+		urls := found[:0]
+		for _, url := range found {
+			if url = extract.TrimURL(url); !extract.IsEmptyURL(url) {
+				urls = append(urls, extract.LowerScheme(url))
+			}
+		}
+		return urls
+	})
+}
+
+// inputLine is a line of input with its origin
+type inputLine struct {
+	text   string
+	source string
+	num    int
+}
+
+// Match is an item found in the input
+type Match struct {
+	Value  string
+	Source string
+	// Type is the content category of the URL
+	Type string
+	// Label is shown in the menu instead of the value when set
+	Label string
+	Line  int
+	// Count is the number of times the value was found in the input
+	Count int
+	// Check is the result of checking the URL, set in check mode
+	Check *checkResult
+	// Score is the confidence that the item is not a false positive, set
+	// with --min-confidence or --show-score
+	Score int
+	// Context is the text around the match, set with --capture-window
+	Context string
+	// Parent is the URL the item was decoded from, set with
+	// --decode-embedded
+	Parent string
+}
+
+// origin returns where the match was found as source:line
+func (m *Match) origin() string {
+	return m.Source + ":" + strconv.Itoa(m.Line)
+}
+
+// formatItem returns the text shown for the item in the list output, or
+// in the menu when inMenu is set
+func (o *Options) formatItem(m *Match, i int, inMenu bool) string {
+	s := m.Value
+	if inMenu && m.Label != "" {
+		s = m.Label
+	} else if !inMenu {
+		s = o.outputValue(s)
 	}
 
-	split := strings.Split(s, " ")
-	if len(split) == 1 {
+	if o.Index {
+		s = "[" + strconv.Itoa(i+1) + "] " + s
+	}
+
+	if o.ShowScore {
+		s = fmt.Sprintf("%3d %s", m.Score, s)
+	}
+
+	if m.Context != "" {
+		if !inMenu {
+			s += "\t" + m.Context
+		} else if menu.Dim != nil {
+			s += "  " + menu.Dim(m.Context)
+		} else {
+			s += "  " + m.Context
+		}
+	}
+
+	if inMenu && m.Parent != "" {
+		from := "from " + m.Parent
+		if menu.Dim != nil {
+			from = menu.Dim(from)
+		}
+		s += "  " + from
+	}
+
+	if !o.Source {
 		return s
 	}
 
-	return split[1]
+	if !inMenu {
+		return m.origin() + "\t" + s
+	}
+
+	origin := m.origin()
+	if menu.Dim != nil {
+		origin = menu.Dim(origin)
+	}
+
+	return s + "  " + origin
 }
 
 // outputData outputs the URLs to STDOUT
-func outputData(urls []string) {
-	for _, url := range urls {
-		fmt.Fprintln(os.Stdout, url)
+func outputData(o *Options, items []Match) {
+	switch o.Format {
+	case formatCSV:
+		logErrAndExit(o.writeTable(items, ','))
+		return
+	case formatTSV:
+		logErrAndExit(o.writeTable(items, '\t'))
+		return
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for i := range items {
+		s := o.formatItem(&items[i], i, false)
+		if c := items[i].Check; c != nil {
+			s = c.String() + "\t" + s
+		}
+		if _, err := w.WriteString(s + "\n"); err != nil {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		logErrAndExit(fmt.Errorf("error writing output: %w", err))
 	}
 }
 
 // copyURL copies the selected URL to the clipboard
 func copyURL(url string) error {
-	err := clipboard.WriteAll(url)
+	var err error
+	if len(platform.Copy) > 0 {
+		err = copyCmd(url)
+	} else {
+		err = clipboard.WriteAll(url)
+	}
 	if err != nil {
 		return fmt.Errorf("error copying to clipboard: %w", err)
 	}
 
 	log.Print("text copied to clipboard: ", url)
+	notify("copied to clipboard: " + url)
 	return nil
 }
 
-// openURL opens the selected URL in the
+// openURL opens the selected URL in the default browser
 func openURL(url string) error {
-	log.Printf("opening URL %s with '%s'\n", url, xdgOpen)
-	cmd := exec.Command(xdgOpen, url)
-	err := cmd.Start()
+	if err := checkSafeURL(url); err != nil {
+		return err
+	}
+
+	if isSyncOpener(xdgOpen) {
+		log.Printf("opening URL %s with %s\n", url, xdgOpen)
+		return openSync(url)
+	}
+
+	// the openers by scheme and the gemini and gopher clients are used
+	// unless --opener is given, and $BROWSER when no opener was chosen
+	var cmd *exec.Cmd
+	var wait bool
+	if o := settingOrigins["opener"]; o != originFlag && o != originMode {
+		cmd = openWithCmd(url)
+		if cmd == nil {
+			cmd, wait = smallnetCmd(url)
+		}
+	}
+	if cmd == nil && xdgOpen == platform.Opener && settingOrigins["opener"] == originDefault {
+		cmd = browserCmd(url)
+	}
+	if cmd == nil {
+		cmd = openCmd(url)
+	}
+	log.Printf("opening URL %s with '%s'\n", url, cmd.Args)
+
+	var err error
+	if wait {
+		err = cmd.Run()
+	} else {
+		err = startCmd(cmd)
+	}
 	if err != nil {
 		return fmt.Errorf("error opening URL: %w", err)
 	}
@@ -153,13 +540,33 @@ func openURL(url string) error {
 
 // Menu is a struct that holds the command and arguments for menu
 type Menu struct {
+	// PromptArgs returns the arguments that set the menu prompt
+	PromptArgs func(s string) []string
+	// Dim returns the text styled as secondary information, if the menu
+	// supports it
+	Dim       func(s string) string
 	Command   string
 	Arguments []string
+	// MultiArgs are the arguments that enable selecting several items
+	MultiArgs []string
+	// Run shows the menu without running a command, for the builtin one
+	Run func(m *Menu, s string) (string, error)
+	// Icon returns the item with the image shown next to it, for menus
+	// that support icons
+	Icon func(s, path string) string
+	// Streams is set for the menus that show the items while reading them,
+	// they get the items as they are found
+	Streams bool
 }
 
 // prompt sets the prompt for the menu
 func (m *Menu) prompt(s string) {
-	m.Arguments = append(m.Arguments, "-p", s)
+	if m.PromptArgs == nil {
+		m.Arguments = append(m.Arguments, "-p", s)
+		return
+	}
+
+	m.Arguments = append(m.Arguments, m.PromptArgs(s)...)
 }
 
 // addArgs adds additional arguments to the menu
@@ -168,29 +575,129 @@ func (m *Menu) addArgs() {
 		return
 	}
 
-	args := strings.Split(menuArgsFlag, " ")
-	m.Arguments = append(m.Arguments, args...)
+	m.Arguments = append(m.Arguments, commandArgs(menuArgsFlag)...)
 }
 
 // handlePrompt handles the menu prompt
 func (m *Menu) handlePrompt() {
-	switch {
-	case openFlag:
-		m.prompt("OpenURL>")
-	case copyFlag:
-		m.prompt("CopyURL>")
-	default:
-		m.prompt("GoURLs>")
+	if promptFlag != "" {
+		m.prompt(promptFlag)
+		return
 	}
+
+	m.prompt(actionPrompt(selectedAction()))
+}
+
+// showsIcons reports whether the menu is set to show icons, like rofi with
+// -show-icons
+func (m *Menu) showsIcons() bool {
+	return m.Icon != nil && inList(m.Arguments, "-show-icons")
+}
+
+// newMenu returns a copy of the platform menu with the user arguments and
+// the prompt
+func newMenu(prompt string) Menu {
+	m := platform.Menu
+	m.Arguments = append([]string(nil), m.Arguments...)
+	m.addArgs()
+	m.prompt(prompt)
+
+	return m
+}
+
+// wantsMulti reports whether the action allows selecting several items in
+// the menu, for action menus any of their actions
+func wantsMulti(name string) bool {
+	names := menuActions(name, nil)
+	if names == nil {
+		names = []string{name}
+	}
+
+	for _, n := range names {
+		if a, err := lookupAction(n); err == nil && allowsMulti(a) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // show runs the menu command and returns the selected item
 func (m *Menu) show(s string) (string, error) {
 	log.Println("running menu:", m.Command, m.Arguments)
+	if m.Run != nil {
+		return m.Run(m, s)
+	}
+	path, err := exec.LookPath(m.Command)
+	if err != nil {
+		return m.missingMenu(s)
+	}
+
+	return m.runCommand(path, len(s), func(cmd *exec.Cmd) error {
+		if s != "" {
+			cmd.Stdin = strings.NewReader(s)
+		}
+		return nil
+	})
+}
+
+// showStream runs the menu command writing the lines to it as they are
+// sent, the menus that do not stream get them all once the channel is
+// closed
+func (m *Menu) showStream(first []string, rest <-chan string) (string, error) {
+	path, err := exec.LookPath(m.Command)
+	if !m.Streams || m.Run != nil || err != nil {
+		lines := first
+		for line := range rest {
+			lines = append(lines, line)
+		}
+		return m.show(strings.Join(lines, "\n"))
+	}
+
+	log.Println("running menu:", m.Command, m.Arguments)
+	return m.runCommand(path, -1, func(cmd *exec.Cmd) error {
+		w, err := cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("error creating input pipe: %w", err)
+		}
+		go feedMenu(w, first, rest)
+		return nil
+	})
+}
+
+// feedMenu writes the lines to the menu input, until the channel is closed
+// or the menu exits. The lines left after the menu exits are read and
+// dropped, so the scan sending them is not blocked.
+func feedMenu(w io.WriteCloser, first []string, rest <-chan string) {
+	defer func() {
+		for range rest {
+		}
+	}()
+	defer w.Close()
+	for _, line := range first {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return
+		}
+	}
+	for line := range rest {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return
+		}
+	}
+}
+
+// runCommand runs the menu command at path with the input set by stdin,
+// of size bytes or -1 when streamed, and returns the selected item
+func (m *Menu) runCommand(path string, size int, stdin func(cmd *exec.Cmd) error) (string, error) {
 	cmd := exec.Command(m.Command, m.Arguments...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if debugMenuFlag {
+		debugMenu(path, cmd.Args, size)
+	}
 
-	if s != "" {
-		cmd.Stdin = strings.NewReader(s)
+	if err := stdin(cmd); err != nil {
+		return "", err
 	}
 
 	stdoutPipe, err := cmd.StdoutPipe()
@@ -209,93 +716,289 @@ func (m *Menu) show(s string) (string, error) {
 	}
 
 	err = cmd.Wait()
+	logMenuStderr(stderr.String())
+	if debugMenuFlag {
+		fmt.Fprintf(os.Stderr, "debug-menu: %s, %d bytes of output\n", cmd.ProcessState, len(output))
+	}
 	if err != nil {
-		return "", fmt.Errorf("error waiting for menu: %w", err)
+		return "", menuError(m.Command, err, len(output) == 0, stderr.String())
 	}
 
 	outputStr := string(output)
-	outputStr = strings.TrimRight(outputStr, "\n")
+	outputStr = strings.TrimRight(outputStr, "\r\n")
 	log.Println("selected:", outputStr)
 
 	return outputStr, nil
 }
 
-var menu = Menu{
-	Command: "dmenu",
-	Arguments: []string{
-		"-i",
-		"-l", "10",
-	},
+var menu = platform.Menu
+
+// splitLongLine cuts the line where extract.LineCut does, so no match spans
+// the cut and every byte is scanned once, and returns the chunk and the
+// part carried over to the next chunk. A run of size bytes without a place
+// to cut is cut at size.
+func splitLongLine(line []byte, size int) (chunk, carry []byte) {
+	i := extract.LineCut(line, size)
+	if i < 0 {
+		log.Printf("cutting a run of %d bytes without blanks, the matches across the cut are split", size)
+		i = size
+	}
+
+	return line[:i], line[i:]
+}
+
+// chunkSize is about the size of the text of the lines scanned at once
+const chunkSize = 4 << 20
+
+// lineChunks collects the lines read in chunks. The text of the lines is
+// copied to a buffer reused for every chunk and converted to a string once
+// per chunk, the lines are slices of it.
+type lineChunks struct {
+	buf   []byte
+	spans []lineSpan
+	// count is the number of lines added
+	count int
+	flush func([]inputLine)
+}
+
+// lineSpan is a line of the chunk buffer, ending at end
+type lineSpan struct {
+	end    int
+	source string
+	num    int
+}
+
+func (c *lineChunks) add(text []byte, source string, num int) {
+	c.buf = append(c.buf, text...)
+	c.spans = append(c.spans, lineSpan{end: len(c.buf), source: source, num: num})
+	c.count++
+	if len(c.buf) >= chunkSize {
+		c.emit()
+	}
 }
 
-// processInputData processes the input from stdin
-func processInputData(r io.Reader) []string {
-	var data []string
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		if limitFlag > 0 && len(data) >= limitFlag {
+// emit passes the lines collected to flush and starts a new chunk
+func (c *lineChunks) emit() {
+	if len(c.spans) == 0 {
+		return
+	}
+
+	text := string(c.buf)
+	lines := make([]inputLine, len(c.spans))
+	start := 0
+	for i, sp := range c.spans {
+		lines[i] = inputLine{text: text[start:sp.end], source: sp.source, num: sp.num}
+		start = sp.end
+	}
+	c.buf, c.spans = c.buf[:0], c.spans[:0]
+	c.flush(lines)
+}
+
+// readChunks reads the lines from the input sources and passes them to fn
+// in chunks, so the input is never held in memory at once. Lines longer
+// than --max-line-bytes are split in chunks.
+func (o *Options) readChunks(sources []source, fn func([]inputLine)) {
+	c := &lineChunks{flush: fn}
+	for _, src := range sources {
+		if o.Limit > 0 && c.count >= o.Limit {
 			break
 		}
-		line := scanner.Text()
-		data = append(data, line)
+		o.readLines(c, src)
 	}
+	c.emit()
+}
+
+// processInputData reads all the lines from the input sources
+func (o *Options) processInputData(sources []source) []inputLine {
+	var data []inputLine
+	o.readChunks(sources, func(lines []inputLine) {
+		data = append(data, lines...)
+	})
+
 	return data
 }
 
-// uniqueItems removes duplicates from a slice
-func uniqueItems(input []string) []string {
-	seen := make(map[string]bool)
-	var result []string
-	for _, ok := range input {
-		if !seen[ok] {
-			seen[ok] = true
-			result = append(result, ok)
+// readLines adds the lines of the source to the chunks
+func (o *Options) readLines(c *lineChunks, src source) {
+	var line []byte
+	num := 1
+	sec := o.newSection()
+
+	// with --tail the lines are kept in a ring until the end of the input
+	var tail *lineRing
+	if o.Tail > 0 {
+		tail = newLineRing(o.Tail)
+	}
+	add := func(text []byte) {
+		if !sec.keep(num, text) {
+			return
+		}
+		if tail != nil {
+			tail.push(inputLine{text: string(text), source: src.name, num: num})
+			return
+		}
+		c.add(text, src.name, num)
+	}
+
+	br := bufio.NewReaderSize(src.r, 64<<10)
+	for {
+		if o.Limit > 0 && c.count >= o.Limit || sec.done {
+			break
+		}
+
+		frag, isPrefix, err := br.ReadLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("error reading input: %s", err)
+			}
+			break
+		}
+
+		line = append(line, frag...)
+		for len(line) > o.MaxLine {
+			chunk, carry := splitLongLine(line, o.MaxLine)
+			add(chunk)
+			line = append(line[:0], carry...)
+		}
+
+		if isPrefix {
+			continue
+		}
+
+		add(line)
+		line = line[:0]
+		num++
+	}
+
+	if tail != nil {
+		for _, l := range tail.ordered() {
+			if o.Limit > 0 && c.count >= o.Limit {
+				break
+			}
+			c.add([]byte(l.text), l.source, l.num)
+		}
+	}
+}
+
+// sortByInput sorts the items in the order they appear in the input,
+// following the order of the sources, since each finder returns its own
+// matches
+func sortByInput(items []Match, sources []source) {
+	order := make(map[string]int, len(sources))
+	for i, src := range sources {
+		if _, ok := order[src.name]; !ok {
+			order[src.name] = i
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if order[a.Source] != order[b.Source] {
+			return order[a.Source] < order[b.Source]
 		}
+		return a.Line < b.Line
+	})
+}
+
+// reverseItems sorts the items from the last found in the input to the
+// first
+func reverseItems(items []Match, sources []source) {
+	sortByInput(items, sources)
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// uniqueItems removes duplicates from a slice, keeping the first occurrence
+// and counting how many times each value was found
+func uniqueItems(input []Match) []Match {
+	seen := make(map[string]int)
+	var result []Match
+	for _, m := range input {
+		if i, ok := seen[m.Value]; ok {
+			result[i].Count++
+			continue
+		}
+		seen[m.Value] = len(result)
+		m.Count = 1
+		result = append(result, m)
 	}
 	return result
 }
 
-// addIndex adds an index to the items
-func addIndex(items []string) []string {
-	for i, url := range items {
-		items[i] = fmt.Sprintf("[%d] %s", i+1, url)
+// filterByCount returns the items found at least n times
+func filterByCount(items []Match, n int) []Match {
+	var result []Match
+	for _, m := range items {
+		if m.Count >= n {
+			result = append(result, m)
+		}
 	}
-	return items
+	return result
+}
+
+// filterByLength returns the items with a length between min and max
+// characters, max 0 has no limit
+func filterByLength(items []Match, minLen, maxLen int) []Match {
+	var result []Match
+	for _, m := range items {
+		n := utf8.RuneCountInString(m.Value)
+		if n >= minLen && (maxLen == 0 || n <= maxLen) {
+			result = append(result, m)
+		}
+	}
+	return result
 }
 
 // scanItems scans the input data and returns the found match
-func scanItems(data []string, find func(string) []string) []string {
-	var items []string
-	index := 1
+func scanItems(data []inputLine, find func(string) []string) []Match {
+	var items []Match
 	for _, line := range data {
-		found := find(line)
+		found := find(line.text)
 		for _, item := range found {
-			items = append(items, item)
-			index++
+			// copied, a slice of the line keeps its whole chunk in memory
+			item = strings.Clone(decodeEntities(item))
+			items = append(items, Match{
+				Value:  item,
+				Source: line.source,
+				Type:   classify(item),
+				Line:   line.num,
+			})
 		}
 	}
 	return items
 }
 
 // scanURLs scans the input data and returns the found URLs
-func scanURLs(data []string, find func(string) []string, resultsCh chan []string) {
+func scanURLs(data []inputLine, find func(string) []string, resultsCh chan []Match) {
 	items := scanItems(data, find)
 	resultsCh <- items
 }
 
-func getURLsFrom(r io.Reader, finders ...func(string) []string) ([]string, error) {
-	resultsCh := make(chan []string)
-	data := processInputData(r)
-	results := make([]string, 0)
-
-	// Start finders
-	for _, f := range finders {
-		go scanURLs(data, f, resultsCh)
+func (o *Options) getURLsFrom(sources []source, onItems func([]Match), finders ...func(string) []string) ([]Match, error) {
+	results := make([]Match, 0)
+	scan := func(data []inputLine) {
+		// the items are added in the order of the finders, whichever ends
+		// first, so the output is the same on every run
+		resultsCh := make([]chan []Match, len(finders))
+		for i, f := range finders {
+			resultsCh[i] = make(chan []Match, 1)
+			go scanURLs(data, f, resultsCh[i])
+		}
+		n := len(results)
+		for _, ch := range resultsCh {
+			results = append(results, <-ch...)
+		}
+		if onItems != nil {
+			onItems(results[n:])
+		}
 	}
 
-	// Wait for all finders to finish
-	for range finders {
-		results = append(results, <-resultsCh...)
+	if o.Multiline {
+		// the wrapped URLs can span two chunks
+		scan(unwrapLines(o.processInputData(sources)))
+	} else {
+		o.readChunks(sources, scan)
 	}
 
 	if len(results) == 0 {
@@ -304,75 +1007,253 @@ func getURLsFrom(r io.Reader, finders ...func(string) []string) ([]string, error
 	return results, nil
 }
 
-// selectURL runs menu and returns the selected URL
-func selectURL(urls []string) string {
-	itemsString := strings.Join(urls, "\n")
-	output, err := menu.show(itemsString)
-	if err != nil {
-		return ""
+// ansiEscape matches the ANSI escape sequences used to style menu items
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// selectURL runs menu and returns the selected items, the text shown in
+// the menu is mapped back to its match so the index and origin never reach
+// the actions
+func selectURL(o *Options, items []Match) ([]Match, bool) {
+	var icons map[string]string
+	if menu.showsIcons() {
+		icons = favicons(items)
+	}
+
+	lines := make([]string, 0, len(items))
+	shown := make(map[string]Match, len(items))
+	for i := range items {
+		s := o.formatItem(&items[i], i, true)
+		shown[ansiEscape.ReplaceAllString(s, "")] = items[i]
+		if home := itemHome(items[i].Value); home != nil && icons[home.Host] != "" {
+			s = menu.Icon(s, icons[home.Host])
+		}
+		lines = append(lines, s)
 	}
 
+	output, err := showPaged(lines)
+	exitOnMenuError(err)
+
+	return selectedItems(output, shown)
+}
+
+// selectedItems returns the items selected in the menu output, from the
+// text shown for them, or the text typed in the menu
+func selectedItems(output string, shown map[string]Match) ([]Match, bool) {
 	selectedStr := strings.Trim(output, "\n")
 	if selectedStr == "" {
 		printInfo("no <URL> selected")
-		return ""
+		return nil, false
 	}
 
-	return selectedStr
+	var selected []Match
+	for _, s := range strings.Split(selectedStr, "\n") {
+		s = strings.TrimRight(s, "\r")
+		if m, ok := shown[ansiEscape.ReplaceAllString(s, "")]; ok {
+			selected = append(selected, m)
+			continue
+		}
+
+		// text typed in the menu instead of picking an item
+		selected = append(selected, Match{Value: s, Type: classify(s)})
+	}
+
+	return selected, true
 }
 
-func handleURLAction(url string) {
-	actions := map[bool]func(url string) error{
-		copyFlag: copyURL,
-		openFlag: openURL,
+func handleURLAction(o *Options, selected []Match) {
+	name := selectedAction()
+	if name == "" {
+		// No action, just output
+		for _, m := range selected {
+			fmt.Println(o.outputValue(m.Value))
+		}
+		return
 	}
 
-	if action, ok := actions[true]; ok {
-		logErrAndExit(action(removeIdx(url)))
-		os.Exit(0)
+	if hooks.has(hookChooseAction) {
+		name = actionHook(name, &selected[0])
 	}
 
-	// No action, just output
-	fmt.Println(url)
+	if names := menuActions(name, selected); names != nil {
+		var ok bool
+		if name, ok = pickAction(names); !ok {
+			printInfo("no action selected")
+			return
+		}
+	}
+
+	action, err := lookupAction(name)
+	logErrAndExit(err)
+
+	if !allowsMulti(action) {
+		selected = selected[:1]
+	}
+
+	selected = o.schemeItems(selected)
+	if peekFlag && !peekSelection(selected) {
+		printInfo("cancelled")
+		return
+	}
+
+	logErrAndExit(action.Run(context.Background(), o, selected))
+	for i := range selected {
+		addHistory(&selected[i], name)
+	}
+	if waitBackground(actionGrace) {
+		os.Exit(1)
+	}
+	os.Exit(0)
 }
 
-func findWithCustomRegex(r io.Reader, regex string) []string {
-	finder := newRegexMatcherWithPrefix(regex, "")
-	items, err := getURLsFrom(r, finder)
-	if err != nil {
-		logErrAndExit(err)
+// customMatcher returns the finder of the custom regex, adding --prefix
+// and --suffix to the matches
+func (o *Options) customMatcher(regex string) func(string) []string {
+	pattern := o.customPattern(regex)
+	find := newRegexMatcherWithPrefix(pattern, o.Prefix)
+	// every match starts with the literal prefix of the regex
+	if literal, _ := regexp.MustCompile(pattern).LiteralPrefix(); literal != "" {
+		find = o.withPrefilter(func(line string) bool { return strings.Contains(line, literal) }, find)
+	}
+	if o.Suffix == "" {
+		return find
 	}
 
-	return items
+	return func(line string) []string {
+		found := find(line)
+		for i := range found {
+			found[i] += o.Suffix
+		}
+		return found
+	}
 }
 
-func findItems(r io.Reader) []string {
-	findURL := newRegexMatcherWithPrefix(urlRegex, "")
-	findEmail := newRegexMatcherWithPrefix(emailRegex, "mailto:")
-	items, err := getURLsFrom(r, findURL, findEmail)
-	if err != nil {
-		logErrAndExit(err)
+// finder finds the items of a line, name is shown by test-regex
+type finder struct {
+	name string
+	find func(string) []string
+}
+
+// namedFinders returns the finders used with the current flags
+func (o *Options) namedFinders() []finder {
+	if o.Regex != "" {
+		return []finder{{name: "regex", find: o.customMatcher(o.Regex)}}
 	}
 
-	return items
+	found := []finder{
+		{name: "url", find: o.newURLFinder()},
+		{name: "email", find: o.withPrefilter(mayHaveEmail, newEmailFinder())},
+	}
+	if o.Refang {
+		found = append(found, finder{name: "defanged", find: o.newRefangFinder()})
+	}
+
+	return found
+}
+
+// finders returns the functions of the finders used with the current flags
+func (o *Options) finders() []func(string) []string {
+	named := o.namedFinders()
+	found := make([]func(string) []string, 0, len(named))
+	for _, f := range named {
+		found = append(found, f.find)
+	}
+
+	return found
+}
+
+// extractItems returns the items found in the sources with the custom
+// regex, or with the built-in finders, passing them to onItems as they
+// are found when it is not nil
+func (o *Options) extractItems(sources []source, onItems func([]Match)) ([]Match, error) {
+	if o.Regex != "" {
+		return o.customItems(sources, o.Regex, onItems)
+	}
+
+	return o.getURLsFrom(sources, onItems, o.finders()...)
 }
 
-func handleItems(items []string) {
+// pickedItems returns the item chosen without the menu with --first,
+// --last or --select, nil otherwise
+func pickedItems(items []Match) ([]Match, error) {
+	switch {
+	case firstFlag:
+		return items[:1], nil
+	case lastFlag:
+		return items[len(items)-1:], nil
+	case selectFlag == 0:
+		return nil, nil
+	}
+
+	// like the --index numbers, negative ones count from the end
+	i := selectFlag - 1
+	if selectFlag < 0 {
+		i = len(items) + selectFlag
+	}
+	if i < 0 || i >= len(items) {
+		return nil, fmt.Errorf("%w: --select %d out of range, %d items found", errInvalidFlag, selectFlag, len(items))
+	}
+
+	return items[i : i+1], nil
+}
+
+// handleSelection prints the selected items as JSON with
+// --print-selection-json, or runs the action on them
+func handleSelection(o *Options, items, selected []Match) {
+	if selJSONFlag {
+		logErrAndExit(printSelectionJSON(items, selected))
+		return
+	}
+
+	handleURLAction(o, selected)
+}
+
+func handleItems(o *Options, items []Match) {
+	picked, err := pickedItems(items)
+	logErrAndExit(err)
+	selects := selectedAction() != "" || selJSONFlag
+
 	// If no action flags are passed, just print the URLs
-	if !copyFlag && !openFlag && menuArgsFlag == "" {
-		outputData(items)
+	if !selects && menuArgsFlag == "" && picked == nil {
+		outputData(o, items)
 		return
 	}
 
 	menu.addArgs()
 	menu.handlePrompt()
 
-	url := selectURL(items)
-	if url == "" {
+	if picked != nil {
+		handleSelection(o, items, picked)
 		return
 	}
 
-	handleURLAction(url)
+	// a single item is selected without showing the menu
+	if len(items) == 1 && selects && !noAutoSelFlag {
+		handleSelection(o, items, items)
+		return
+	}
+
+	if forgeFlag {
+		enrichForge(items, forgeAPIFlag)
+	}
+
+	if mediaInfoFlag {
+		enrichMedia(items)
+	}
+
+	if decodeFlag {
+		decodeForDisplay(items)
+	}
+
+	if selJSONFlag && selectedAction() == "" || wantsMulti(selectedAction()) {
+		menu.Arguments = append(menu.Arguments, menu.MultiArgs...)
+	}
+
+	selected, ok := selectURL(o, items)
+	if !ok {
+		return
+	}
+
+	handleSelection(o, items, selected)
 }
 
 func version() string {
@@ -380,57 +1261,298 @@ func version() string {
 }
 
 func init() {
-	xdgOpen = "xdg-open"
-
 	flag.BoolVar(&copyFlag, "c", false, "copy to clipboard")
 	flag.BoolVar(&copyFlag, "copy", false, "copy to clipboard")
 
 	flag.BoolVar(&openFlag, "o", false, "open in browser")
 	flag.BoolVar(&openFlag, "open", false, "open in browser")
 
-	flag.IntVar(&limitFlag, "l", 0, "limit number of URLs")
-	flag.IntVar(&limitFlag, "limit", 0, "limit number of URLs")
+	flag.StringVar(&xdgOpen, "opener", platform.Opener, "command used to open URLs")
+	flag.StringVar(&portalFlag, "portal", portalAuto, "use the desktop portal")
+
+	flag.BoolVar(&playFlag, "p", false, "play with media player")
+	flag.BoolVar(&playFlag, "play", false, "play with media player")
+	flag.StringVar(&actionFlag, "A", "", "run action on the selection")
+	flag.StringVar(&actionFlag, "action", "", "run action on the selection")
+	flag.BoolVar(&readFlag, "read", false, "show the article of the selected page")
+	flag.StringVar(&readOutputFlag, "read-output", "", "write the article to a file")
+	flag.BoolVar(&laterFlag, "later", false, "add the selection to the reading list")
+	flag.BoolVar(&peekFlag, "peek", false, "show the response headers and ask before running the action")
+	flag.BoolVar(&firstFlag, "first", false, "run the action on the first item found")
+	flag.BoolVar(&lastFlag, "last", false, "run the action on the last item found")
+	flag.IntVar(&selectFlag, "select", 0, "run the action on the Nth item")
+	flag.BoolVar(&noAutoSelFlag, "no-auto-select", false, "show the menu even when a single item is found")
+	flag.BoolVar(&askActionFlag, "ask-action", false, "choose the action from a menu after selecting")
+	flag.StringVar(&playerFlag, "player", "mpv", "media player")
+	flag.StringVar(&composeFlag, "compose-cmd", "", "mail client used to open emails")
+	flag.StringVar(&previewFlag, "preview", previewNone, "show images of the links in the builtin menu")
+	flag.BoolVar(&mediaInfoFlag, "media-info", false, "show title and duration of media links")
 
 	flag.BoolVar(&verboseFlag, "v", false, "verbose mode")
 	flag.BoolVar(&verboseFlag, "verbose", false, "verbose mode")
 
-	flag.BoolVar(&indexFlag, "i", false, "indexed menu")
-	flag.BoolVar(&indexFlag, "index", false, "indexed menu")
-
-	flag.StringVar(&customRegexFlag, "E", "", "custom regex")
-	flag.StringVar(&customRegexFlag, "regex", "", "custom regex")
+	flag.BoolVar(&decodeFlag, "decode-display", false, "show percent-decoded URLs in the menu")
+	flag.BoolVar(&transformFlag, "transform", false, "rewrite the URLs given one per line")
 
 	flag.StringVar(&menuArgsFlag, "a", "", "additional args for dmenu")
 	flag.StringVar(&menuArgsFlag, "menu-args", "", "additional args for dmenu")
+	flag.StringVar(&promptFlag, "prompt", "", "prompt of the menu")
+
+	flag.BoolVar(&daemonFlag, "daemon", false, "keep recent URLs sent with --send")
+	flag.BoolVar(&sendFlag, "send", false, "send input to the daemon")
+	flag.BoolVar(&fromDaemonFlag, "from-daemon", false, "read input from the daemon")
+	flag.IntVar(&daemonSizeFlag, "daemon-size", 500, "number of URLs kept by the daemon")
+	flag.StringVar(&socketFlag, "socket", "", "path of the daemon socket")
+
+	flag.BoolVar(&historyFlag, "history", false, "save the selection to the history")
+	flag.BoolVar(&noHistoryFlag, "no-history", false, "do not save the selection to the history")
+
+	flag.StringVar(&configFlag, "config", "", "path of the config file")
 
 	flag.BoolVar(&versionFlag, "V", false, "output version information")
 	flag.BoolVar(&versionFlag, "version", false, "output version information")
+	flag.BoolVar(&jsonFlag, "json", false, "output version information as JSON")
+
+	flag.BoolVar(&fallbackFlag, "menu-fallback", false, "use the builtin menu when the menu command is not found")
+	flag.BoolVar(&debugMenuFlag, "debug-menu", false, "print the menu command line, environment and exit status")
+	flag.BoolVar(&selJSONFlag, "print-selection-json", false, "print the selection as JSON instead of running an action")
+	flag.StringVar(&modeFlag, "mode", "", "apply the flags of the mode in the config")
+	flag.IntVar(&pageSizeFlag, "page-size", 0, "show the items in the menu in pages of N")
+	flag.BoolVar(&interactiveFlag, "interactive", false, "explore the items with commands at a prompt")
+	flag.BoolVar(&timelineFlag, "timeline", false, "show when the URLs of log lines were found")
+	flag.BoolVar(&benchFlag, "bench", false, "compare the time and the items of the fast and compat matchers")
+	flag.BoolVar(&intersectFlag, "intersect", false, "print the URLs found in all the files")
+	flag.BoolVar(&unionFlag, "union", false, "print the URLs found in any of the files")
+	flag.StringVar(&metricsFlag, "metrics", "", "write metrics of the run to file in the OpenMetrics format")
+	flag.StringVar(&webhookFlag, "webhook", "", "post the newly broken or found URLs to the webhook URL")
+	flag.StringVar(&webhookTmplFlag, "webhook-template", "", "template of the webhook payload")
+	flag.BoolVar(&checkFlag, "check", false, "check the URLs found")
+	flag.BoolVar(&checkDNSFlag, "check-dns", false, "check the URLs found resolving their hosts")
+	flag.IntVar(&retriesFlag, "retries", 2, "retries of transient failures")
+	flag.DurationVar(&backoffFlag, "retry-backoff", time.Second, "delay before the first retry")
+	flag.DurationVar(&actTimeoutFlag, "action-timeout", 0, "wait for the actions to finish, killing them after the duration")
+	flag.StringVar(&failOnFlag, "fail-on", failOnBroken, "exit with error on")
+	flag.StringVar(&snapshotFlag, "snapshot", "", "save the response of each URL to dir")
+	flag.IntVar(&perHostFlag, "per-host-concurrency", 2, "concurrent requests to the same host")
+	flag.BoolVar(&forgeFlag, "enrich-forge", false, "show forge links as labels in the menu")
+	flag.BoolVar(&forgeAPIFlag, "forge-api", false, "fetch titles from the forge API")
+
+	flag.BoolVar(&clipboardFlag, "from-clipboard", false, "read input from clipboard")
+	flag.BoolVar(&primaryFlag, "primary-in", false, "read input from the primary selection when there is no stdin")
+	flag.BoolVar(&tmuxFlag, "tmux", false, "read input from current tmux pane")
+	flag.StringVar(&ocrFlag, "ocr", "", "read input from text in image")
+	flag.StringVar(&qrFlag, "qr-decode", "", "read input from QR codes in image")
+	flag.BoolVar(&screenshotFlag, "screenshot", false, "read input from QR codes in a screenshot")
+	flag.StringVar(&dataURIFlag, "extract-data-uris", "", "write the content of the data URIs found to dir")
+	flag.StringVar(&hooksFlag, "hooks", "", "Starlark script with the hooks")
+	flag.Var(&fromPluginFlag, "from-plugin", "read input from the output of source plugins")
 
 	flag.Usage = printUsage
+}
+
+// parseFlags parses the command line and loads the config, exiting on
+// invalid flags, and returns the extraction options given
+func parseFlags() *Options {
+	opts := newOptions()
+	opts.registerFlags(flag.CommandLine)
 	flag.Parse()
 
+	// the mode sets flags, so it is applied before they are validated
+	var err error
+	config, err = loadConfig()
+	if err == nil {
+		err = applyMode(&config, opts, modeFlag)
+	}
+	configErr = err
+
+	setVerboseLevel()
+
+	if checkDNSFlag {
+		checkFlag = true
+	}
+	logErrAndExit(validateOutputFormat(opts.Format))
+	logErrAndExit(validatePreview(previewFlag))
+	logErrAndExit(validateFailOn(failOnFlag))
+	logErrAndExit(applyPortalMode(portalFlag))
+
+	err = configErr
+	if err == nil {
+		err = applySettings(&config, opts)
+	}
+	if err == nil {
+		err = registerConfigActions(&config)
+	}
+	if err == nil {
+		err = registerUnwrapRules(&config)
+	}
+	// config check reports the problems of the config file itself
+	if flag.Arg(0) != "config" && !versionFlag {
+		logErrAndExit(err)
+	}
+	configErr = err
+
 	if versionFlag {
-		fmt.Print(version())
+		printVersion()
 		os.Exit(0)
 	}
 
-	setVerboseLevel()
+	if actionFlag != "" && actionFlag != actionCustom {
+		_, err := lookupAction(actionFlag)
+		logErrAndExit(err)
+	}
+
+	if webhookFlag != "" {
+		logErrAndExit(validateWebhook(webhookFlag, webhookTmplFlag))
+	}
+
+	for name, s := range map[string]string{"--menu-args": menuArgsFlag, "--compose-cmd": composeFlag} {
+		if _, err := splitArgs(s); err != nil {
+			logErrAndExit(fmt.Errorf("%w: %s: %w", errInvalidFlag, name, err))
+		}
+	}
+
+	if actTimeoutFlag < 0 {
+		logErrAndExit(fmt.Errorf("%w: --action-timeout must not be negative", errInvalidFlag))
+	}
+
+	if pageSizeFlag < 0 {
+		logErrAndExit(fmt.Errorf("%w: --page-size must not be negative", errInvalidFlag))
+	}
+
+	if daemonSizeFlag < 1 {
+		logErrAndExit(fmt.Errorf("%w: --daemon-size must be greater than 0", errInvalidFlag))
+	}
+
+	if retriesFlag < 0 {
+		logErrAndExit(fmt.Errorf("%w: --retries must not be negative", errInvalidFlag))
+	}
+
+	if perHostFlag < 1 {
+		logErrAndExit(fmt.Errorf("%w: --per-host-concurrency must be greater than 0", errInvalidFlag))
+	}
+
+	if intersectFlag && unionFlag {
+		logErrAndExit(fmt.Errorf("%w: --intersect and --union cannot be used together", errInvalidFlag))
+	}
+
+	if firstFlag && lastFlag || (firstFlag || lastFlag) && selectFlag != 0 {
+		logErrAndExit(fmt.Errorf("%w: only one of --first, --last and --select can be used", errInvalidFlag))
+	}
+
+	logErrAndExit(opts.validate())
+
+	return opts
+}
+
+// subcommands are run when their name is the first argument
+var subcommands = map[string]func(o *Options, args []string) error{
+	"history":    runHistory,
+	"later":      runLater,
+	"config":     runConfig,
+	"test-regex": runTestRegex,
+	"clipwatch":  runClipwatch,
+	"diff":       runDiff,
+	"plugins":    runPlugins,
+	"selftest":   runSelftest,
 }
 
 func main() {
-	var items []string
+	opts := parseFlags()
+	catchBrokenPipe()
+	defer func() {
+		if waitBackground(actionGrace) {
+			os.Exit(1)
+		}
+	}()
 
-	if customRegexFlag != "" {
-		items = findWithCustomRegex(os.Stdin, customRegexFlag)
-	} else {
-		items = findItems(os.Stdin)
+	if cmd, ok := subcommands[flag.Arg(0)]; ok {
+		logErrAndExit(cmd(opts, flag.Args()[1:]))
+		return
+	}
+
+	if path, ok := pluginFor(flag.Arg(0)); ok {
+		logErrAndExit(runPluginCommand(opts, path, flag.Args()[1:]))
+		return
+	}
+
+	if daemonFlag {
+		logErrAndExit(runDaemon(opts))
+		return
 	}
 
-	items = uniqueItems(items)
+	if intersectFlag || unionFlag {
+		paths, err := opts.inputPaths(flag.Args())
+		logErrAndExit(err)
+		logErrAndExit(runSetOp(opts, paths))
+		return
+	}
+
+	sources, err := opts.inputSources()
+	logErrAndExit(err)
+
+	if hooksFlag != "" {
+		hooks, err = loadHooks(hooksFlag)
+		logErrAndExit(err)
+		opts.hooks = hooks
+	}
 
-	if indexFlag {
-		items = addIndex(items)
+	if sendFlag {
+		logErrAndExit(sendToDaemon(sources))
+		return
+	}
+
+	if transformFlag {
+		logErrAndExit(runTransform(opts, sources))
+		return
+	}
+
+	if dataURIFlag != "" {
+		logErrAndExit(extractDataURIs(opts, sources, dataURIFlag))
+		return
+	}
+
+	if timelineFlag {
+		logErrAndExit(runTimeline(opts, sources))
+		return
+	}
+
+	if benchFlag {
+		logErrAndExit(runBench(opts, sources))
+		return
+	}
+
+	if streamsToMenu(opts) {
+		handleStream(opts, sources)
+		return
+	}
+
+	ex, err := opts.extract(sources, nil)
+	logErrAndExit(err)
+	items, found := ex.items, ex.found
+	stats.extracted, stats.unique = len(found), ex.unique
+
+	if checkFlag {
+		err := runCheck(opts, items, found)
+		if metricsFlag != "" {
+			logErrAndExit(writeMetrics(metricsFlag))
+		}
+		logErrAndExit(err)
+		return
+	}
+
+	if metricsFlag != "" {
+		logErrAndExit(writeMetrics(metricsFlag))
+	}
+
+	if interactiveFlag {
+		logErrAndExit(runInteractive(opts, items))
+		return
+	}
+
+	if snapshotFlag != "" && selectedAction() == "" && menuArgsFlag == "" {
+		logErrAndExit((snapshotAction{}).Run(context.Background(), opts, items))
+		return
 	}
 
-	handleItems(items)
+	handleItems(opts, items)
 }
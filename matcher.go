@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// matchers of --matcher
+const (
+	// matcherFast skips the lines without the literals every match has
+	matcherFast = "fast"
+	// matcherCompat runs the regexes on every line
+	matcherCompat = "compat"
+)
+
+var (
+	errUnknownMatcher = errors.New("unknown matcher")
+	errBench          = errors.New("the matchers found different items")
+)
+
+// validateMatcher checks the value of the --matcher flag
+func (o *Options) validateMatcher() error {
+	switch o.Matcher {
+	case matcherFast, matcherCompat:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q (valid: %s, %s)", errUnknownMatcher, o.Matcher, matcherFast, matcherCompat)
+}
+
+// withPrefilter returns the finder skipping the lines for which may is
+// false, with the fast matcher. The prefilters look for the literals every
+// match has, which is much faster than running the regexes.
+func (o *Options) withPrefilter(may func(string) bool, find func(string) []string) func(string) []string {
+	if o.Matcher != matcherFast {
+		return find
+	}
+
+	return func(line string) []string {
+		if !may(line) {
+			return nil
+		}
+		return find(line)
+	}
+}
+
+// mayHaveEmail reports whether the line can have an email, with an '@' or
+// one obfuscated in brackets or as an HTML entity
+func mayHaveEmail(line string) bool {
+	return strings.ContainsAny(line, "@&[({<")
+}
+
+// mayBeDefanged reports whether the line can have a defanged URL or
+// domain, the schemes have '//' and the dots are in brackets
+func mayBeDefanged(line string) bool {
+	return strings.Contains(line, "//") || strings.ContainsAny(line, "[({")
+}
+
+// benchResult is the extraction of the input with a matcher
+type benchResult struct {
+	matcher string
+	elapsed time.Duration
+	matches int
+	values  map[string]bool
+}
+
+// benchMatcher extracts the items of the input with the matcher, the
+// sources are read from the inputs so every matcher scans the same text
+func benchMatcher(base *Options, matcher string, sources []source, inputs [][]byte) (*benchResult, error) {
+	o := *base
+	o.Matcher = matcher
+	replay := make([]source, len(sources))
+	for i, src := range sources {
+		replay[i] = source{r: bytes.NewReader(inputs[i]), name: src.name}
+	}
+
+	start := time.Now()
+	items, err := o.extractItems(replay, nil)
+	elapsed := time.Since(start)
+	if err != nil && !errors.Is(err, errNoURLFound) {
+		return nil, err
+	}
+
+	r := &benchResult{matcher: matcher, elapsed: elapsed, matches: len(items), values: make(map[string]bool)}
+	for i := range items {
+		r.values[items[i].Value] = true
+	}
+
+	return r, nil
+}
+
+// onlyIn returns the values of a missing from b, sorted
+func onlyIn(a, b map[string]bool) []string {
+	var values []string
+	for v := range a {
+		if !b[v] {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+
+	return values
+}
+
+// runBench extracts the items of the input with both matchers and reports
+// the time they took, and the items found by only one of them to check the
+// fast matcher misses nothing
+func runBench(o *Options, sources []source) error {
+	inputs := make([][]byte, len(sources))
+	var size int
+	for i, src := range sources {
+		b, err := io.ReadAll(src.r)
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		inputs[i] = b
+		size += len(b)
+	}
+
+	var results []*benchResult
+	for _, matcher := range []string{matcherFast, matcherCompat} {
+		r, err := benchMatcher(o, matcher, sources, inputs)
+		if err != nil {
+			return err
+		}
+		mbps := float64(size) / (1 << 20) / max(r.elapsed.Seconds(), 1e-9)
+		fmt.Printf("%-7s %10s  %8.1f MB/s  %d matches, %d unique\n",
+			r.matcher+":", r.elapsed.Round(time.Millisecond), mbps, r.matches, len(r.values))
+		results = append(results, r)
+	}
+
+	fast, compat := results[0], results[1]
+	missed, extra := onlyIn(compat.values, fast.values), onlyIn(fast.values, compat.values)
+	for _, v := range missed {
+		fmt.Printf("missed by %s: %s\n", matcherFast, o.outputValue(v))
+	}
+	for _, v := range extra {
+		fmt.Printf("only found by %s: %s\n", matcherFast, o.outputValue(v))
+	}
+	if fast.matches != compat.matches {
+		fmt.Printf("%s found %d matches, %s %d\n", matcherFast, fast.matches, matcherCompat, compat.matches)
+	}
+	if len(missed) > 0 || len(extra) > 0 || fast.matches != compat.matches {
+		return errBench
+	}
+
+	speedup := compat.elapsed.Seconds() / max(fast.elapsed.Seconds(), 1e-9)
+	fmt.Printf("%s ran %.1fx as fast as %s and found the same items\n", matcherFast, speedup, matcherCompat)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mediaInfoTimeout = 15 * time.Second
+	mediaInfoWorkers = 4
+)
+
+// isMedia reports whether the item is a video or audio URL
+func isMedia(m *Match) bool {
+	return m.Type == typeVideo || m.Type == typeAudio
+}
+
+// playURL plays the selected URL with the media player
+func playURL(url string) error {
+	if err := checkSafeURL(url); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(playerFlag, url)
+	log.Printf("playing URL %s with '%s'\n", url, cmd.Args)
+	if err := startCmd(cmd); err != nil {
+		return fmt.Errorf("error playing URL: %w", err)
+	}
+
+	return nil
+}
+
+// fetchMediaInfo returns the title and duration of the media with yt-dlp
+func fetchMediaInfo(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mediaInfoTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "yt-dlp",
+		"--skip-download", "--no-warnings", "--no-playlist",
+		"--print", "%(title)s [%(duration_string)s]",
+		url,
+	).Output()
+	if err != nil {
+		return "", fmt.Errorf("error fetching media info: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// enrichMedia sets the label of video and audio items to their title and
+// duration
+func enrichMedia(items []Match) {
+	if _, err := exec.LookPath("yt-dlp"); err != nil {
+		log.Printf("media info: %s: yt-dlp", errMissingCmd)
+		return
+	}
+
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < mediaInfoWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				info, err := fetchMediaInfo(items[i].Value)
+				if err != nil {
+					log.Printf("%s: %s", items[i].Value, err)
+					continue
+				}
+				items[i].Label = info
+			}
+		}()
+	}
+
+	for i := range items {
+		if isMedia(&items[i]) {
+			ch <- i
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// menuBackends are the menu commands looked for when the menu is not found
+var menuBackends = []string{"dmenu", "rofi", "fzf", "bemenu", "wofi", "fuzzel", "choose"}
+
+var (
+	errMenuNotFound = errors.New("menu command not found")
+	errMenuFailed   = errors.New("menu failed")
+)
+
+// exitCanceled is the exit status when the menu is canceled, the one of
+// fzf and of the shells for Ctrl-C
+const exitCanceled = 130
+
+// menuErrLines is the number of lines of the menu messages in its errors,
+// the last ones, where menus print why they failed
+const menuErrLines = 3
+
+// menuEnv are the environment variables shown by --debug-menu, the ones
+// menus need to find the display and the terminal
+var menuEnv = []string{
+	"DISPLAY", "WAYLAND_DISPLAY", "XDG_SESSION_TYPE", "XDG_RUNTIME_DIR",
+	"TERM", "TMUX", "LANG", "LC_ALL", "PATH",
+}
+
+// availableMenus returns the menu commands found in PATH
+func availableMenus() []string {
+	var found []string
+	for _, name := range menuBackends {
+		if _, err := exec.LookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+
+	return found
+}
+
+// missingMenu shows the lines in the builtin menu with --menu-fallback
+// when the menu command is not found, or returns an error listing the menus
+// that are
+func (m *Menu) missingMenu(s string) (string, error) {
+	if fallbackFlag {
+		log.Printf("menu %q not found, using the builtin menu", m.Command)
+		fb := builtin
+		fb.Arguments = m.Arguments
+		return fb.Run(&fb, s)
+	}
+
+	hint := "none of " + strings.Join(menuBackends, ", ") + " found"
+	if found := availableMenus(); len(found) > 0 {
+		hint = "found: " + strings.Join(found, ", ")
+	}
+
+	return "", fmt.Errorf("%w: %q (%s), choose one with GOURL_MENU or the menu key of the config, or use the builtin menu with GOURL_MENU=builtin or --menu-fallback",
+		errMenuNotFound, m.Command, hint)
+}
+
+// menuError returns the error of the menu that exited with err. Menus exit
+// with 1 when canceled, fzf with 130, without output nor messages; bad
+// arguments and missing displays exit with 1 too, but print the reason.
+func menuError(command string, err error, noOutput bool, stderr string) error {
+	var exitErr *exec.ExitError
+	stderr = strings.TrimSpace(stderr)
+	if errors.As(err, &exitErr) && noOutput && stderr == "" {
+		if code := exitErr.ExitCode(); code == 1 || code == exitCanceled {
+			return errMenuCanceled
+		}
+	}
+	if stderr != "" {
+		return fmt.Errorf("%w: %s: %w: %s", errMenuFailed, command, err, lastLines(stderr, menuErrLines))
+	}
+
+	return fmt.Errorf("%w: %s: %w", errMenuFailed, command, err)
+}
+
+// exitOnMenuError exits with exitCanceled when the menu was canceled, and
+// with the error when it failed
+func exitOnMenuError(err error) {
+	if errors.Is(err, errMenuCanceled) {
+		log.Println("menu canceled")
+		os.Exit(exitCanceled)
+	}
+	logErrAndExit(err)
+}
+
+// logMenuStderr logs the messages the menu printed, with -v
+func logMenuStderr(stderr string) {
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if line != "" {
+			log.Printf("menu: %s", line)
+		}
+	}
+}
+
+// debugMenu prints the command line of the menu, ready to be pasted in a
+// shell, and the environment it runs in
+func debugMenu(path string, args []string, input int) {
+	fmt.Fprintf(os.Stderr, "debug-menu: %s\n", quoteArgs(args))
+	if input < 0 {
+		fmt.Fprintf(os.Stderr, "debug-menu: path %s, streamed input\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "debug-menu: path %s, %d bytes of input\n", path, input)
+	}
+	for _, name := range menuEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			fmt.Fprintf(os.Stderr, "debug-menu: %s=%s\n", name, quoteArgs([]string{v}))
+		} else {
+			fmt.Fprintf(os.Stderr, "debug-menu: %s is not set\n", name)
+		}
+	}
+}
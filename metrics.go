@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runStats are the counters of the run, written as metrics with --metrics
+type runStats struct {
+	extracted int
+	unique    int
+	checked   int
+	permanent int
+	transient int
+	// statuses counts the HTTP status codes of the checked URLs
+	statuses map[int]int
+	// checkTime is how long checking the URLs took
+	checkTime time.Duration
+}
+
+var stats = runStats{statuses: make(map[int]int)}
+
+// countChecks adds the results of the checked items to the stats
+func (s *runStats) countChecks(items []Match) {
+	for i := range items {
+		c := items[i].Check
+		if c == nil {
+			continue
+		}
+		s.checked++
+		if c.Status != 0 {
+			s.statuses[c.Status]++
+		}
+		switch {
+		case !c.broken():
+		case c.transient():
+			s.transient++
+		default:
+			s.permanent++
+		}
+	}
+}
+
+// openMetrics returns the stats in the OpenMetrics text format, the one
+// read by Prometheus and the node_exporter textfile collector
+func (s *runStats) openMetrics(now time.Time) string {
+	var b strings.Builder
+	metric := func(name, kind, help string, samples ...string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, sample := range samples {
+			b.WriteString(sample + "\n")
+		}
+	}
+	sample := func(name, labels string, v any) string {
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		return fmt.Sprintf("%s%s %v", name, labels, v)
+	}
+
+	metric("gourl_urls_extracted", "counter", "URLs found in the input, with repetitions.",
+		sample("gourl_urls_extracted_total", "", s.extracted))
+	metric("gourl_urls_unique", "gauge", "Distinct URLs found in the input.",
+		sample("gourl_urls_unique", "", s.unique))
+
+	if checkFlag {
+		metric("gourl_links_checked", "counter", "URLs checked.",
+			sample("gourl_links_checked_total", "", s.checked))
+		metric("gourl_broken_links", "counter", "Broken URLs found by the check.",
+			sample("gourl_broken_links_total", `kind="permanent"`, s.permanent),
+			sample("gourl_broken_links_total", `kind="transient"`, s.transient))
+
+		codes := make([]int, 0, len(s.statuses))
+		for code := range s.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		samples := make([]string, 0, len(codes))
+		for _, code := range codes {
+			samples = append(samples, sample("gourl_check_responses_total", `code="`+strconv.Itoa(code)+`"`, s.statuses[code]))
+		}
+		metric("gourl_check_responses", "counter", "HTTP responses of the checked URLs by status code.", samples...)
+
+		metric("gourl_check_duration_seconds", "gauge", "Time spent checking the URLs.",
+			sample("gourl_check_duration_seconds", "", s.checkTime.Seconds()))
+	}
+
+	metric("gourl_last_run_timestamp_seconds", "gauge", "When the run ended.",
+		sample("gourl_last_run_timestamp_seconds", "", now.Unix()))
+	b.WriteString("# EOF\n")
+
+	return b.String()
+}
+
+// writeMetrics writes the stats of the run to path, replacing the file at
+// once so collectors never read it half written
+func writeMetrics(path string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(stats.openMetrics(time.Now())), 0o644); err != nil {
+		return fmt.Errorf("error writing metrics: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing metrics: %w", err)
+	}
+
+	return nil
+}
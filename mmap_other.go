@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// mapFile is only supported on unix systems, the files are read instead
+func mapFile(*os.File, int64) (io.Reader, error) {
+	return nil, errors.ErrUnsupported
+}
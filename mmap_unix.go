@@ -0,0 +1,48 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// mappedFile reads a file mapped in memory, the pages are loaded by the
+// kernel as they are read instead of the file being copied to the heap
+type mappedFile struct {
+	data []byte
+	off  int
+}
+
+// mapFile maps the file in memory, the mapping is released once the reader
+// is no longer used
+func mapFile(f *os.File, size int64) (io.Reader, error) {
+	if int64(int(size)) != size {
+		return nil, errors.ErrUnsupported
+	}
+
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mappedFile{data: b}
+	runtime.SetFinalizer(m, func(m *mappedFile) { _ = syscall.Munmap(m.data) })
+
+	return m, nil
+}
+
+func (m *mappedFile) Read(p []byte) (int, error) {
+	if m.off >= len(m.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[m.off:])
+	m.off += n
+	// the mapping must not be released while it is copied
+	runtime.KeepAlive(m)
+
+	return n, nil
+}
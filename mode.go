@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// originMode is the origin of the settings set by --mode
+const originMode = "mode"
+
+// modeNames returns the names of the modes of the config, sorted
+func modeNames(c *Config) []string {
+	names := make([]string, 0, len(c.Modes))
+	for name := range c.Modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// modeValues returns the flag values of the mode value: true or false for
+// bool flags, numbers, strings, and arrays for the flags that can be given
+// several times
+func modeValues(v any) ([]string, error) {
+	switch v := v.(type) {
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		var values []string
+		for _, e := range v {
+			s, err := modeValues(e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s...)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %v", v)
+}
+
+// settingFor returns the setting of the key, or of the flag with the name
+func settingFor(name string) *setting {
+	for i := range settings {
+		if settings[i].key == name || inList(settings[i].flags, name) {
+			return &settings[i]
+		}
+	}
+
+	return nil
+}
+
+// checkMode returns the problems of the mode: keys that are neither flags
+// nor settings, and values of the wrong type
+func checkMode(mode map[string]any) []string {
+	var problems []string
+	for key, v := range mode {
+		if flag.Lookup(key) == nil && settingFor(key) == nil {
+			problems = append(problems, fmt.Sprintf("unknown flag %q", key))
+			continue
+		}
+		if _, err := modeValues(v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, err))
+		}
+	}
+	sort.Strings(problems)
+
+	return problems
+}
+
+// applyMode sets the flags and settings of the mode in the config, the
+// flags given in the command line take precedence
+func applyMode(c *Config, o *Options, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	mode, ok := c.Modes[name]
+	if !ok {
+		return fmt.Errorf("%w: --mode %q not in the config (available: %s)", errInvalidFlag, name, strings.Join(modeNames(c), ", "))
+	}
+	if problems := checkMode(mode); len(problems) > 0 {
+		return fmt.Errorf("%w: modes.%s: %s", errConfig, name, strings.Join(problems, ", "))
+	}
+
+	keys := make([]string, 0, len(mode))
+	for key := range mode {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if flagSet(key) {
+			continue
+		}
+		values, _ := modeValues(mode[key])
+		s := settingFor(key)
+		for _, v := range values {
+			var err error
+			if flag.Lookup(key) != nil {
+				err = flag.Set(key, v)
+			} else {
+				err = s.set(o, v)
+			}
+			if err != nil {
+				return fmt.Errorf("%w: modes.%s: %s: %w", errConfig, name, key, err)
+			}
+		}
+		if s != nil {
+			settingOrigins[s.key] = originMode
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// inputBlock is the text the custom regex runs over, a line of the input,
+// or with --multiline all the lines of a source joined by newlines
+type inputBlock struct {
+	inputLine
+	// starts are the offsets the lines begin at in the text, nums their
+	// line numbers
+	starts []int
+	nums   []int
+}
+
+// lineAt returns the number of the line the offset is in
+func (b *inputBlock) lineAt(off int) int {
+	return b.nums[sort.SearchInts(b.starts, off+1)-1]
+}
+
+// inputBlocks returns the blocks of the input lines, one per line, or one
+// per source with --multiline
+func (o *Options) inputBlocks(data []inputLine) []inputBlock {
+	blocks := make([]inputBlock, 0, len(data))
+	// the text of the last block is built here and set when it ends, so
+	// each line is copied once
+	var text strings.Builder
+	end := func() {
+		if n := len(blocks); o.Multiline && n > 0 {
+			blocks[n-1].text = text.String()
+		}
+	}
+	for i := range data {
+		l := data[i]
+		if n := len(blocks); o.Multiline && n > 0 && blocks[n-1].source == l.source {
+			b := &blocks[n-1]
+			text.WriteByte('\n')
+			b.starts = append(b.starts, text.Len())
+			b.nums = append(b.nums, l.num)
+			text.WriteString(l.text)
+			continue
+		}
+		end()
+		blocks = append(blocks, inputBlock{inputLine: l, starts: []int{0}, nums: []int{l.num}})
+		if o.Multiline {
+			text = strings.Builder{}
+			text.WriteString(l.text)
+		}
+	}
+	end()
+
+	return blocks
+}
+
+// removeNewlines joins the lines of a match spanning several of them
+func removeNewlines(s string) string {
+	return strings.NewReplacer("\r\n", "", "\n", "").Replace(s)
+}
+
+// wrapEnds are the characters a line broken inside a URL ends with
+const wrapEnds = "/=?&#%_.~-"
+
+var (
+	// wrappedURL matches a URL running to the end of the line that goes on
+	// in the next one
+	wrappedURL = regexp.MustCompile(`(?:(?:https?|gopher|gemini|ftps?|git)://|www\.)\S*[` + wrapEnds + `]$`)
+	// urlTail matches a line continuing a wrapped URL
+	urlTail = regexp.MustCompile(`^[\p{L}\p{N}/=?&#%_.~+-]`)
+)
+
+// blanks are the characters \S does not match
+const blanks = "\t\n\f\r "
+
+// unwrapLines joins the lines of the URLs broken by hard wraps, the joined
+// line keeps the number of the first one
+func unwrapLines(data []inputLine) []inputLine {
+	lines := make([]inputLine, 0, len(data))
+	// the text of the last line is built here and set when the next one
+	// starts, wrapped is set when it ends with a URL going on in the next
+	var text strings.Builder
+	var wrapped bool
+	end := func() {
+		if n := len(lines); n > 0 {
+			lines[n-1].text = text.String()
+		}
+	}
+	for _, l := range data {
+		if n := len(lines); n > 0 && lines[n-1].source == l.source && wrapped && urlTail.MatchString(l.text) {
+			text.WriteString(l.text)
+			if strings.ContainsAny(l.text, blanks) {
+				wrapped = wrappedURL.MatchString(l.text)
+			} else {
+				// the wrapped URL goes on to the end of the line
+				wrapped = strings.ContainsRune(wrapEnds, rune(l.text[len(l.text)-1]))
+			}
+			continue
+		}
+		end()
+		lines = append(lines, l)
+		text = strings.Builder{}
+		text.WriteString(l.text)
+		wrapped = wrappedURL.MatchString(l.text)
+	}
+	end()
+
+	return lines
+}
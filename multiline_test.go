@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testLines returns the lines of the text as input lines of one source
+func testLines(text string) []inputLine {
+	var data []inputLine
+	for i, s := range strings.Split(text, "\n") {
+		data = append(data, inputLine{text: s, num: i + 1, source: "test"})
+	}
+
+	return data
+}
+
+func TestUnwrapLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "wrapped url",
+			input: "see https://example.com/a/\nb/c?x=1&\ny=2 end\nnext",
+			want:  []string{"see https://example.com/a/b/c?x=1&y=2 end", "next"},
+		},
+		{
+			name:  "url ending the line",
+			input: "see https://example.com/a\nnext line",
+			want:  []string{"see https://example.com/a", "next line"},
+		},
+		{
+			name:  "tail with a blank",
+			input: "www.example.com/\npath and https://b.example/\nmore",
+			want:  []string{"www.example.com/path and https://b.example/more"},
+		},
+		{
+			name:  "not a url tail",
+			input: "https://example.com/\n  indented",
+			want:  []string{"https://example.com/", "  indented"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, l := range unwrapLines(testLines(tt.input)) {
+				got = append(got, l.text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputBlocks(t *testing.T) {
+	o := newOptions()
+	o.Multiline = true
+	blocks := o.inputBlocks(testLines("a\nbb\nccc"))
+	if len(blocks) != 1 || blocks[0].text != "a\nbb\nccc" {
+		t.Fatalf("got %+v, want one block with the lines", blocks)
+	}
+	if b := &blocks[0]; b.lineAt(0) != 1 || b.lineAt(2) != 2 || b.lineAt(5) != 3 {
+		t.Errorf("lineAt: got %d %d %d, want 1 2 3", b.lineAt(0), b.lineAt(2), b.lineAt(5))
+	}
+}
+
+// allocated returns the bytes allocated by fn
+func allocated(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+// multilineInput returns n lines, each continuing the URL of the one before
+func multilineInput(n int) []inputLine {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "line %d https://example.com/p/%d/\n", i, i)
+	}
+
+	return testLines(b.String())
+}
+
+// TestMultilineLarge checks the blocks are built without copying the text
+// on every line, which made --multiline quadratic: four times the lines
+// must allocate about four times the bytes, not sixteen. The ratio is
+// checked instead of the bytes, the race detector adds allocations.
+func TestMultilineLarge(t *testing.T) {
+	small, large := multilineInput(1000), multilineInput(4000)
+
+	o := newOptions()
+	o.Multiline = true
+	for _, c := range []struct {
+		name string
+		fn   func([]inputLine)
+	}{
+		{"inputBlocks", func(data []inputLine) { o.inputBlocks(data) }},
+		{"unwrapLines", func(data []inputLine) { unwrapLines(data) }},
+	} {
+		n := allocated(func() { c.fn(small) })
+		m := allocated(func() { c.fn(large) })
+		if m > 8*n {
+			t.Errorf("%s allocated %d bytes for 1000 lines and %d for 4000", c.name, n, m)
+		}
+	}
+}
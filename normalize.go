@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// htmlEntity matches a complete HTML entity, the semicolon is required so
+// query strings like '?a=1&copy=2' are left alone
+var htmlEntity = regexp.MustCompile(`&(?:[a-zA-Z][a-zA-Z0-9]*|#[0-9]+|#[xX][0-9a-fA-F]+);`)
+
+// decodeEntities decodes the HTML entities in a match, like the '&amp;' in
+// links copied from raw HTML. Anything after a decoded quote or bracket is
+// dropped, it was not part of the URL.
+func decodeEntities(s string) string {
+	if !strings.Contains(s, "&") {
+		return s
+	}
+
+	s = htmlEntity.ReplaceAllStringFunc(s, html.UnescapeString)
+	if i := strings.IndexAny(s, "\"'<>"); i >= 0 {
+		s = s[:i]
+	}
+
+	return s
+}
+
+// displayLabel returns the URL percent-decoded for display, or an empty
+// string when decoding changes nothing or gives invalid text
+func displayLabel(s string) string {
+	d, err := url.PathUnescape(s)
+	if err != nil || d == s {
+		return ""
+	}
+
+	return d
+}
+
+// decodeForDisplay sets the percent-decoded URL as the label of the items,
+// the encoded URL is still used by the actions
+func decodeForDisplay(items []Match) {
+	for i := range items {
+		if items[i].Label != "" {
+			continue
+		}
+		items[i].Label = displayLabel(items[i].Value)
+	}
+}
+
+// percentEncodeNonASCII percent-encodes the bytes of the non-ASCII
+// characters, leaving everything else as it is
+func percentEncodeNonASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// normalizeIRI returns the IRI as an URI for the actions: the host with
+// punycode and the rest percent-encoded, as chosen with the flags
+func normalizeIRI(s string, punycode, percentEncode bool) string {
+	scheme, rest, found := strings.Cut(s, "://")
+	if !found {
+		scheme, rest = "", s
+	}
+
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	authority, tail := rest[:end], rest[end:]
+
+	if punycode {
+		userinfo, hostport, ok := strings.Cut(authority, "@")
+		if !ok {
+			userinfo, hostport = "", authority
+		}
+		host, port, hasPort := strings.Cut(hostport, ":")
+		hostport = hostToASCII(host)
+		if hasPort {
+			hostport += ":" + port
+		}
+		authority = hostport
+		if ok {
+			authority = userinfo + "@" + hostport
+		}
+	}
+
+	if percentEncode {
+		tail = percentEncodeNonASCII(tail)
+	}
+
+	if scheme == "" {
+		return authority + tail
+	}
+
+	return scheme + "://" + authority + tail
+}
+
+// normalizeIRIs converts the values of the items for the actions, keeping
+// the readable form as the label shown in the menu
+func normalizeIRIs(items []Match, punycode, percentEncode bool) {
+	for i := range items {
+		v := normalizeIRI(items[i].Value, punycode, percentEncode)
+		if v == items[i].Value {
+			continue
+		}
+		if items[i].Label == "" {
+			items[i].Label = items[i].Value
+		}
+		items[i].Value = v
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Options are the settings of an extraction: the part of the input that is
+// scanned, what is matched, and how the items are rewritten and filtered.
+// The extraction takes them instead of reading globals, so extractions with
+// different options can run at the same time.
+type Options struct {
+	// Input is the type the input is read as, Encoding its text encoding
+	// and MinRun the length of the strings kept from binary input
+	Input    string
+	Encoding string
+	Binary   bool
+	MinRun   int
+	// Include and Exclude are the patterns of the archive members scanned
+	Include   listFlag
+	Exclude   listFlag
+	Recursive bool
+	// Limit is the number of input lines scanned, 0 scans them all
+	Limit int
+	// MaxLine is the length in bytes lines are split at
+	MaxLine int
+	// Lines, After and Before select the part of each source scanned, and
+	// Head and Tail the number of its lines
+	Lines     string
+	After     string
+	Before    string
+	Head      int
+	Tail      int
+	Multiline bool
+
+	// Regex is the custom regex, the URLs and emails are found without it
+	Regex      string
+	Fixed      bool
+	RegexFlags string
+	Engine     string
+	// Matcher is fast to skip the lines that cannot have a match, compat to
+	// run the regexes on every line
+	Matcher string
+	Prefix  string
+	Suffix  string
+	// Window is the number of characters of context kept around the
+	// matches of the custom regex
+	Window      int
+	Refang      bool
+	SchemeAllow listFlag
+	SchemeDeny  listFlag
+
+	// Embedded adds the URLs encoded inside the URLs found
+	Embedded bool
+	// Normalize adds the DefaultScheme to the www. links found
+	Normalize     bool
+	DefaultScheme string
+	Unwrap        bool
+	Clean         bool
+	Expand        bool
+	Canonical     bool
+	Punycode      bool
+	Encode        bool
+
+	MinLength int
+	MaxLength int
+	Reverse   bool
+	MinCount  int
+	MinScore  int
+	ShowScore bool
+	// Index numbers the items listed and shown in the menu
+	Index    bool
+	OnlyType listFlag
+	Where    string
+	// Prefer is the file of the domains listed first
+	Prefer      string
+	VerifyEmail bool
+
+	// Source writes where each item was found, Defang the items defanged,
+	// and Format is the format of the list output
+	Source bool
+	Defang bool
+	Format string
+
+	// lines, after, before and where are parsed by validate
+	lines  lineRange
+	after  *regexp.Regexp
+	before *regexp.Regexp
+	where  whereExpr
+	// schemeAllow and schemeDeny are the schemes given, in lowercase
+	schemeAllow []string
+	schemeDeny  []string
+	// hooks is the hooks script run on the items, nil without one
+	hooks *hookScript
+}
+
+// newOptions returns the options with their default values
+func newOptions() *Options {
+	return &Options{
+		Input:         inputAuto,
+		Encoding:      encAuto,
+		MinRun:        6,
+		MaxLine:       1 << 20,
+		Engine:        engineRE2,
+		Matcher:       matcherFast,
+		DefaultScheme: "https",
+		MinCount:      1,
+		Format:        formatText,
+	}
+}
+
+// registerFlags defines the flags of the options in the flag set, with the
+// current values as their defaults
+func (o *Options) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Input, "input", o.Input, "input type")
+	fs.StringVar(&o.Encoding, "encoding", o.Encoding, "input encoding")
+	fs.BoolVar(&o.Binary, "binary", o.Binary, "scan printable strings in binary files")
+	fs.IntVar(&o.MinRun, "min-run", o.MinRun, "minimum length of strings in binary mode")
+	fs.Var(&o.Include, "include", "only scan archive members matching pattern")
+	fs.Var(&o.Exclude, "exclude", "skip archive members matching pattern")
+	fs.BoolVar(&o.Recursive, "R", o.Recursive, "read the files in directories")
+	fs.BoolVar(&o.Recursive, "recursive", o.Recursive, "read the files in directories")
+
+	fs.IntVar(&o.Limit, "l", o.Limit, "limit number of URLs")
+	fs.IntVar(&o.Limit, "limit", o.Limit, "limit number of URLs")
+	fs.IntVar(&o.MaxLine, "max-line-bytes", o.MaxLine, "split lines longer than this")
+	fs.StringVar(&o.Lines, "lines", o.Lines, "only scan these lines of each input")
+	fs.StringVar(&o.After, "after-match", o.After, "only scan the lines after the one matching regex")
+	fs.StringVar(&o.Before, "before-match", o.Before, "only scan the lines before the one matching regex")
+	fs.IntVar(&o.Head, "head", o.Head, "only scan the first N lines of each input")
+	fs.IntVar(&o.Tail, "tail", o.Tail, "only scan the last N lines of each input")
+	fs.BoolVar(&o.Multiline, "multiline", o.Multiline, "match across lines, joining URLs broken by hard wraps")
+
+	fs.StringVar(&o.Regex, "E", o.Regex, "custom regex")
+	fs.StringVar(&o.Regex, "regex", o.Regex, "custom regex")
+	fs.BoolVar(&o.Fixed, "F", o.Fixed, "match the custom regex as a literal string")
+	fs.BoolVar(&o.Fixed, "fixed-string", o.Fixed, "match the custom regex as a literal string")
+	fs.StringVar(&o.RegexFlags, "regex-flags", o.RegexFlags, "flags of the custom regex, like i,m,s")
+	fs.StringVar(&o.Engine, "engine", o.Engine, "regex engine of the custom regex, re2 or pcre")
+	fs.StringVar(&o.Matcher, "matcher", o.Matcher, "fast skips the lines that cannot have a match, compat runs the regexes on every line")
+	fs.StringVar(&o.Prefix, "prefix", o.Prefix, "text added before each custom regex match")
+	fs.StringVar(&o.Suffix, "suffix", o.Suffix, "text added after each custom regex match")
+	fs.IntVar(&o.Window, "capture-window", o.Window, "characters of context shown around each custom regex match")
+	fs.BoolVar(&o.Refang, "refang", o.Refang, "find defanged URLs and domains")
+	fs.Var(&o.SchemeAllow, "scheme-allow", "only extract URLs with these schemes")
+	fs.Var(&o.SchemeDeny, "scheme-deny", "do not extract URLs with these schemes")
+
+	fs.BoolVar(&o.Embedded, "decode-embedded", o.Embedded, "find the URLs encoded in base64 inside the URLs")
+	fs.BoolVar(&o.Normalize, "normalize", o.Normalize, "add the default scheme to the www. links in the list output too")
+	fs.StringVar(&o.DefaultScheme, "default-scheme", o.DefaultScheme, "scheme added to the www. links given to the actions")
+	fs.BoolVar(&o.Unwrap, "unwrap", o.Unwrap, "replace redirector links with their destination")
+	fs.BoolVar(&o.Clean, "clean", o.Clean, "remove tracking parameters")
+	fs.BoolVar(&o.Expand, "expand", o.Expand, "replace short links with the URL they redirect to")
+	fs.BoolVar(&o.Canonical, "canonicalize-remote", o.Canonical, "replace page URLs with their canonical URL")
+	fs.BoolVar(&o.Punycode, "punycode", o.Punycode, "encode internationalized domains with punycode")
+	fs.BoolVar(&o.Encode, "percent-encode", o.Encode, "percent-encode the non-ASCII characters of the URLs")
+
+	fs.IntVar(&o.MinLength, "min-length", o.MinLength, "only items at least this many characters long")
+	fs.IntVar(&o.MaxLength, "max-length", o.MaxLength, "only items at most this many characters long")
+	fs.BoolVar(&o.Reverse, "reverse", o.Reverse, "list the items from the last found to the first")
+	fs.IntVar(&o.MinCount, "min-count", o.MinCount, "only items found at least this many times")
+	fs.IntVar(&o.MinScore, "min-confidence", o.MinScore, "only items with a confidence score of at least this")
+	fs.BoolVar(&o.ShowScore, "show-score", o.ShowScore, "show the confidence score of each item")
+	fs.BoolVar(&o.Index, "i", o.Index, "indexed menu")
+	fs.BoolVar(&o.Index, "index", o.Index, "indexed menu")
+	fs.Var(&o.OnlyType, "only-type", "only items of type")
+	fs.StringVar(&o.Where, "where", o.Where, "only items matching the expression on the URL components")
+	fs.StringVar(&o.Prefer, "prefer-domains", o.Prefer, "list first the items of the domains in file")
+	fs.BoolVar(&o.VerifyEmail, "verify-email", o.VerifyEmail, "remove emails whose domain does not accept mail")
+
+	fs.BoolVar(&o.Source, "with-source", o.Source, "show where each item was found")
+	fs.BoolVar(&o.Defang, "defang", o.Defang, "defang the URLs printed or copied")
+	fs.StringVar(&o.Format, "output-format", o.Format, "output format")
+}
+
+// validate checks the options and parses the ones used by the extraction
+func (o *Options) validate() error {
+	if o.Binary {
+		o.Input = inputBinary
+	}
+	if err := validateInputType(o.Input); err != nil {
+		return err
+	}
+	if err := validateEncoding(o.Encoding); err != nil {
+		return err
+	}
+
+	var err error
+	if o.lines, err = parseLineRange(o.Lines); err != nil {
+		return err
+	}
+	if o.after, err = compileGate("after-match", o.After); err != nil {
+		return err
+	}
+	if o.before, err = compileGate("before-match", o.Before); err != nil {
+		return err
+	}
+	if o.where, err = parseWhere(o.Where); err != nil {
+		return err
+	}
+
+	o.schemeAllow = lowerAll(o.SchemeAllow)
+	o.schemeDeny = lowerAll(o.SchemeDeny)
+	if err := validateURLTypes(o.OnlyType); err != nil {
+		return err
+	}
+	if err := validateDefaultScheme(o.DefaultScheme); err != nil {
+		return err
+	}
+
+	switch {
+	case o.MinLength < 0 || o.MaxLength < 0 || o.MaxLength > 0 && o.MaxLength < o.MinLength:
+		return fmt.Errorf("%w: --min-length and --max-length must not be negative, and max not less than min", errInvalidFlag)
+	case o.MinCount < 1:
+		return fmt.Errorf("%w: --min-count must be greater than 0", errInvalidFlag)
+	case o.MinScore < 0 || o.MinScore > maxScore:
+		return fmt.Errorf("%w: --min-confidence must be between 0 and %d", errInvalidFlag, maxScore)
+	case o.Head < 0 || o.Tail < 0:
+		return fmt.Errorf("%w: --head and --tail must not be negative", errInvalidFlag)
+	case o.Head > 0 && o.Tail > 0:
+		return fmt.Errorf("%w: --head and --tail cannot be used together", errInvalidFlag)
+	case o.Window < 0:
+		return fmt.Errorf("%w: --capture-window must not be negative", errInvalidFlag)
+	case o.Window > 0 && o.Regex == "":
+		return fmt.Errorf("%w: --capture-window requires -E", errInvalidFlag)
+	case (o.Prefix != "" || o.Suffix != "") && o.Regex == "":
+		return fmt.Errorf("%w: --prefix and --suffix require -E", errInvalidFlag)
+	case o.MaxLine < 2:
+		return fmt.Errorf("%w: --max-line-bytes must be greater than 1", errInvalidFlag)
+	}
+
+	if err := o.validateEngine(); err != nil {
+		return err
+	}
+	if err := o.validateMatcher(); err != nil {
+		return err
+	}
+
+	return o.validateCustomRegex()
+}
+
+// lowerAll returns a copy of the values in lowercase, nil without values
+func lowerAll(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	lower := make([]string, len(values))
+	for i, v := range values {
+		lower[i] = strings.ToLower(v)
+	}
+
+	return lower
+}
+
+// streams reports whether the items can be passed on as they are found,
+// when the extraction keeps or drops each of them on its own, without
+// sorting, counting, scoring or rewriting them all
+func (o *Options) streams() bool {
+	return !o.Multiline && o.Window == 0 && o.Engine != enginePCRE && !o.Embedded &&
+		!o.Clean && !o.Unwrap && !o.Expand && !o.Canonical && !o.Punycode && !o.Encode &&
+		!o.Reverse && o.MinCount <= 1 && o.MinScore == 0 && !o.ShowScore &&
+		!whereUses(o.where, "count", "score") &&
+		o.Prefer == "" && !o.VerifyEmail && !o.hooks.has(hookTransform) && !o.hooks.has(hookOnMatch)
+}
+
+// streamItem returns the item found as the extraction keeps it, false when
+// it is filtered out, for the extractions that stream
+func (o *Options) streamItem(m Match) (Match, bool) {
+	items := []Match{m}
+	if o.Normalize {
+		items = o.schemeItems(items)
+	}
+	if len(o.schemeAllow) > 0 || len(o.schemeDeny) > 0 {
+		items = o.filterBySchemes(items)
+	}
+	if o.MinLength > 0 || o.MaxLength > 0 {
+		items = filterByLength(items, o.MinLength, o.MaxLength)
+	}
+	if len(o.OnlyType) > 0 {
+		items = filterByType(items, o.OnlyType)
+	}
+	if o.where != nil {
+		items = filterWhere(items, o.where)
+	}
+	if len(items) == 0 {
+		return Match{}, false
+	}
+
+	return items[0], true
+}
+
+// extraction is the result of an extraction
+type extraction struct {
+	// items are the distinct items kept by the filters
+	items []Match
+	// found are the items found with their repetitions, before the
+	// filters, for the link check
+	found []Match
+	// unique is the number of distinct items found
+	unique int
+}
+
+// extract finds the items in the sources, rewrites and filters them.
+// onItems, when not nil, is called with the items found in each chunk of
+// the input, before the filters, to stream them.
+func (o *Options) extract(sources []source, onItems func([]Match)) (*extraction, error) {
+	items, err := o.extractItems(sources, onItems)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Embedded {
+		items = o.decodeEmbedded(items)
+	}
+
+	if o.Normalize {
+		items = o.schemeItems(items)
+	}
+
+	if o.Clean || o.Unwrap || o.Expand || o.Canonical || o.Punycode || o.Encode {
+		o.rewriteItems(items)
+	}
+
+	if o.hooks.has(hookTransform) {
+		if err := o.hooks.transformHook(items); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(o.schemeAllow) > 0 || len(o.schemeDeny) > 0 {
+		items = o.filterBySchemes(items)
+	}
+
+	if o.MinLength > 0 || o.MaxLength > 0 {
+		items = filterByLength(items, o.MinLength, o.MaxLength)
+	}
+
+	if o.Reverse {
+		reverseItems(items, sources)
+	}
+
+	found := items
+	items = uniqueItems(items)
+	unique := len(items)
+
+	if o.MinScore > 0 || o.ShowScore {
+		scoreItems(items)
+		items = filterByScore(items, o.MinScore)
+	}
+
+	if o.hooks.has(hookOnMatch) {
+		if items, err = o.hooks.matchHook(items); err != nil {
+			return nil, err
+		}
+		items = filterByScore(items, o.MinScore)
+	}
+
+	if o.MinCount > 1 {
+		items = filterByCount(items, o.MinCount)
+	}
+
+	if len(o.OnlyType) > 0 {
+		items = filterByType(items, o.OnlyType)
+	}
+
+	if o.where != nil {
+		items = filterWhere(items, o.where)
+	}
+
+	if o.Prefer != "" {
+		domains, err := readDomains(o.Prefer)
+		if err != nil {
+			return nil, err
+		}
+		items = preferDomains(items, domains)
+	}
+
+	if o.VerifyEmail {
+		items = verifyEmails(items)
+	}
+
+	if len(items) == 0 {
+		return nil, errNoURLFound
+	}
+
+	return &extraction{items: items, found: found, unique: unique}, nil
+}
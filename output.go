@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// output formats, formatCSV is shared with the history export
+const (
+	formatText   = "text"
+	formatTSV    = "tsv"
+	formatSARIF  = "sarif"
+	formatGitHub = "github"
+)
+
+var outputFormats = []string{formatText, formatCSV, formatTSV, formatSARIF, formatGitHub}
+
+var errOutputFormat = errors.New("unknown output format")
+
+// tableHeader is the header row of the csv and tsv output
+var tableHeader = []string{"url", "type", "count", "line", "source"}
+
+// catchBrokenPipe makes the writes to the output fail with EPIPE when its
+// reader is gone, instead of the program being killed by SIGPIPE, so it
+// stops and exits with status 0 like a Unix filter. The signal is caught
+// and not ignored, so the commands run still get it.
+func catchBrokenPipe() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGPIPE)
+	go func() {
+		for range sig {
+		}
+	}()
+}
+
+// validateOutputFormat checks the value of the --output-format flag
+func validateOutputFormat(s string) error {
+	if !inList(outputFormats, s) {
+		return fmt.Errorf("%w: %q (valid: %s)", errOutputFormat, s, strings.Join(outputFormats, ", "))
+	}
+
+	if (s == formatSARIF || s == formatGitHub) && !checkFlag {
+		return fmt.Errorf("%w: --output-format %s requires --check", errInvalidFlag, s)
+	}
+
+	return nil
+}
+
+// tableRow returns the columns of the item in the csv and tsv output, with
+// the score with --show-score, the context with --capture-window, the
+// parent URL with --decode-embedded and the status of the URL in check mode
+func (o *Options) tableRow(m *Match) []string {
+	row := []string{o.outputValue(m.Value), m.Type, strconv.Itoa(m.Count), strconv.Itoa(m.Line), m.Source}
+	if o.ShowScore {
+		row = append(row, strconv.Itoa(m.Score))
+	}
+	if o.Window > 0 {
+		row = append(row, m.Context)
+	}
+	if o.Embedded {
+		row = append(row, m.Parent)
+	}
+	if !checkFlag {
+		return row
+	}
+
+	var status string
+	if m.Check != nil {
+		status = m.Check.String()
+	}
+
+	return append(row, status)
+}
+
+// writeTable writes the items as rows with a header, separated by sep
+func (o *Options) writeTable(items []Match, sep rune) error {
+	header := tableHeader[:len(tableHeader):len(tableHeader)]
+	if o.ShowScore {
+		header = append(header, "score")
+	}
+	if o.Window > 0 {
+		header = append(header, "context")
+	}
+	if o.Embedded {
+		header = append(header, "parent")
+	}
+	if checkFlag {
+		header = append(header, "status")
+	}
+
+	rows := make([][]string, 0, len(items))
+	for i := range items {
+		rows = append(rows, o.tableRow(&items[i]))
+	}
+
+	return writeRows(header, rows, sep)
+}
+
+// selectionJSON is a selected item as printed by --print-selection-json
+type selectionJSON struct {
+	URL string `json:"url"`
+	// Index is the position of the item in the list, from 1, 0 for text
+	// typed in the menu
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"`
+	Source string `json:"source,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// printSelectionJSON prints the selected items as JSON, one per line, with
+// their position in items
+func printSelectionJSON(items, selected []Match) error {
+	index := make(map[string]int, len(items))
+	for i := range items {
+		index[items[i].Value] = i + 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for i := range selected {
+		m := &selected[i]
+		err := enc.Encode(selectionJSON{
+			URL:    m.Value,
+			Index:  index[m.Value],
+			Type:   m.Type,
+			Label:  m.Label,
+			Source: m.Source,
+			Line:   m.Line,
+			Count:  m.Count,
+		})
+		if err != nil {
+			return fmt.Errorf("error writing selection: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeRows writes the header and the rows separated by sep
+func writeRows(header []string, rows [][]string, sep rune) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Comma = sep
+
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+
+	return nil
+}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRuleID  = "broken-link"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           sarifRegion   `json:"region"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeSARIF writes the broken links as a SARIF log
+func writeSARIF(broken []Match) error {
+	results := make([]sarifResult, 0, len(broken))
+	for _, m := range broken {
+		results = append(results, sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   "error",
+			Message: sarifMessage{Text: fmt.Sprintf("broken link %s: %s", m.Value, m.Check)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(m.Source)},
+					Region:           sarifRegion{StartLine: m.Line},
+				},
+			}},
+		})
+	}
+
+	out := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           appName,
+				Version:        appVersion,
+				InformationURI: "https://github.com/haaag/GoURL",
+				Rules: []sarifRule{{
+					ID:               sarifRuleID,
+					ShortDescription: sarifMessage{Text: "Link could not be fetched or returned an error status"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+
+	return nil
+}
+
+// annotationEscaper escapes the data of a GitHub workflow command
+var annotationEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// annotationPropEscaper escapes the properties of a GitHub workflow command
+var annotationPropEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// writeGitHubAnnotations writes the broken links as GitHub Actions error
+// annotations, shown inline in pull requests
+func writeGitHubAnnotations(broken []Match) error {
+	for _, m := range broken {
+		msg := fmt.Sprintf("broken link %s: %s", m.Value, m.Check)
+		_, err := fmt.Fprintf(os.Stdout, "::error file=%s,line=%d::%s\n",
+			annotationPropEscaper.Replace(filepath.ToSlash(m.Source)), m.Line, annotationEscaper.Replace(msg))
+		if err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+	}
+
+	return nil
+}
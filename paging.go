@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// entries added to the pages of the menu with --page-size
+const (
+	pageNext = "» next page"
+	pagePrev = "« previous page"
+	pageAll  = "… show all"
+)
+
+// pageNav returns the page navigation entry of the menu selection, "" when
+// an item is selected
+func pageNav(output string) string {
+	for _, s := range strings.Split(strings.Trim(output, "\n"), "\n") {
+		s = ansiEscape.ReplaceAllString(strings.TrimRight(s, "\r"), "")
+		for _, nav := range []string{pageNext, pagePrev, pageAll} {
+			if strings.HasPrefix(s, nav) {
+				return nav
+			}
+		}
+	}
+
+	return ""
+}
+
+// showPaged shows the lines in the menu, in pages of --page-size lines
+// with entries to go to the next and previous pages and to show all the
+// lines, so huge lists do not choke menus like dmenu
+func showPaged(lines []string) (string, error) {
+	size := pageSizeFlag
+	if size <= 0 || len(lines) <= size {
+		return menu.show(strings.Join(lines, "\n"))
+	}
+
+	pages := (len(lines) + size - 1) / size
+	for page := 0; ; {
+		shown := append([]string(nil), lines[page*size:min((page+1)*size, len(lines))]...)
+		if page < pages-1 {
+			shown = append(shown, fmt.Sprintf("%s (%d/%d)", pageNext, page+2, pages))
+		}
+		if page > 0 {
+			shown = append(shown, fmt.Sprintf("%s (%d/%d)", pagePrev, page, pages))
+		}
+		shown = append(shown, fmt.Sprintf("%s (%d)", pageAll, len(lines)))
+
+		output, err := menu.show(strings.Join(shown, "\n"))
+		if err != nil {
+			return "", err
+		}
+
+		switch pageNav(output) {
+		case pageNext:
+			page++
+		case pagePrev:
+			page--
+		case pageAll:
+			return menu.show(strings.Join(lines, "\n"))
+		default:
+			return output, nil
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// regex engines of -E
+const (
+	engineRE2  = "re2"
+	enginePCRE = "pcre"
+)
+
+var errEngine = errors.New("unknown regex engine")
+
+// pcreEnv is the variable the pattern is passed to perl in, so it is never
+// parsed as perl code
+const pcreEnv = "GOURL_PCRE"
+
+// pcreScript prints the record number and the byte offsets of each match,
+// the records are lines, or the blocks of the sources separated by NUL
+// with --multiline
+const pcreScript = `BEGIN { $re = qr/$ENV{GOURL_PCRE}/ } while (/$re/g) { print "$.\t$-[0]\t$+[0]\n" }`
+
+// validateEngine checks the value of the --engine flag
+func (o *Options) validateEngine() error {
+	s := o.Engine
+	switch s {
+	case engineRE2:
+		return nil
+	case enginePCRE:
+	default:
+		return fmt.Errorf("%w: %q (valid: %s, %s)", errEngine, s, engineRE2, enginePCRE)
+	}
+
+	if o.Regex == "" {
+		return fmt.Errorf("%w: --engine %s requires -E", errInvalidFlag, s)
+	}
+	if strings.Contains(o.RegexFlags, "U") {
+		return fmt.Errorf("%w: --regex-flags U is not supported by --engine %s", errInvalidFlag, s)
+	}
+	if _, err := exec.LookPath("perl"); err != nil {
+		return fmt.Errorf("%w: --engine %s needs perl: %w", errInvalidFlag, s, err)
+	}
+
+	return nil
+}
+
+// perlCmd returns the perl command running the script with the pattern
+func perlCmd(pattern string, args ...string) *exec.Cmd {
+	cmd := exec.Command("perl", args...)
+	cmd.Env = append(os.Environ(), pcreEnv+"="+pattern)
+
+	return cmd
+}
+
+// checkPCRE reports whether perl accepts the pattern
+func checkPCRE(pattern string) error {
+	var stderr bytes.Buffer
+	cmd := perlCmd(pattern, "-e", "qr/$ENV{"+pcreEnv+"}/")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		return fmt.Errorf("%w: -E: %s", errInvalidFlag, msg)
+	}
+
+	return nil
+}
+
+// findWithPCRE returns the matches of the regex found by perl, which
+// supports the lookarounds and backreferences RE2 does not
+func (o *Options) findWithPCRE(sources []source, regex string) ([]Match, error) {
+	blocks := o.inputBlocks(o.processInputData(sources))
+	sep, args := byte('\n'), []string{"-ne", pcreScript}
+	if o.Multiline {
+		sep, args = 0, []string{"-0", "-ne", pcreScript}
+	}
+
+	var input bytes.Buffer
+	for i := range blocks {
+		input.WriteString(blocks[i].text)
+		input.WriteByte(sep)
+	}
+
+	var stderr bytes.Buffer
+	cmd := perlCmd(o.customPattern(regex), args...)
+	cmd.Stdin = &input
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running perl: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var items []Match
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		num, err1 := strconv.Atoi(fields[0])
+		start, err2 := strconv.Atoi(fields[1])
+		end, err3 := strconv.Atoi(fields[2])
+		if err := errors.Join(err1, err2, err3); err != nil || num < 1 || num > len(blocks) {
+			continue
+		}
+		b := &blocks[num-1]
+		if start < 0 || end > len(b.text) || start >= end {
+			continue
+		}
+		m := o.customMatch(&b.inputLine, start, end)
+		m.Line = b.lineAt(start)
+		items = append(items, m)
+	}
+
+	return items, nil
+}
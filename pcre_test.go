@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPCRE(t *testing.T) {
+	if _, err := exec.LookPath("perl"); err != nil {
+		t.Skip("perl not found")
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  []string
+		lines []int
+	}{
+		{
+			name:  "lookbehind",
+			args:  []string{"-E", `(?<=id=)\d+`},
+			input: "a id=12 b\nc id=345 idx=6",
+			want:  []string{"12", "345"},
+			lines: []int{1, 2},
+		},
+		{
+			name:  "backreference",
+			args:  []string{"-E", `\b(\w)\w*\1\b`},
+			input: "abca xyz\nnoon",
+			want:  []string{"abca", "noon"},
+			lines: []int{1, 2},
+		},
+		{
+			name:  "lookahead and flags",
+			args:  []string{"-E", `ticket-\d+(?!\d|-draft)`, "--regex-flags", "i"},
+			input: "TICKET-12 ticket-7-draft ticket-8",
+			want:  []string{"TICKET-12", "ticket-8"},
+			lines: []int{1, 1},
+		},
+		{
+			name:  "multiline",
+			args:  []string{"-E", `begin\n\w+`, "--multiline"},
+			input: "x\nbegin\nblock\n",
+			want:  []string{"beginblock"},
+			lines: []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := testOptions(t, append(tt.args, "--engine", enginePCRE)...)
+			items := extractInput(t, o, tt.input)
+			lines := make([]int, 0, len(items))
+			for i := range items {
+				lines = append(lines, items[i].Line)
+			}
+			if got := values(items); !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("got %q at lines %v, want %q at lines %v", got, lines, tt.want, tt.lines)
+			}
+		})
+	}
+}
+
+// pcreOptions returns the error of validating the options with the flags
+func pcreOptions(args ...string) error {
+	o := newOptions()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	o.registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return o.validate()
+}
+
+func TestPCREInvalidPattern(t *testing.T) {
+	if _, err := exec.LookPath("perl"); err != nil {
+		t.Skip("perl not found")
+	}
+
+	if err := pcreOptions("--engine", enginePCRE, "-E", `(?<=a`); !errors.Is(err, errInvalidFlag) {
+		t.Errorf("got %v, want %v", err, errInvalidFlag)
+	}
+}
+
+func TestPCREWithoutPerl(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := pcreOptions("--engine", enginePCRE, "-E", `(?<=id=)\d+`)
+	if !errors.Is(err, errInvalidFlag) || !strings.Contains(err.Error(), "needs perl") {
+		t.Errorf("got %v, want an error saying perl is needed", err)
+	}
+	if err := pcreOptions("-E", `\d+`); err != nil {
+		t.Errorf("--engine %s: %s", engineRE2, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const peekTimeout = 10 * time.Second
+
+// peekInfo is what the response headers tell about a URL
+type peekInfo struct {
+	Status      string
+	ContentType string
+	FinalURL    string
+	Length      int64
+}
+
+// formatSize returns the size in bytes in a human readable form
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// lines returns the information to show, one item per line
+func (p *peekInfo) lines(rawURL string) []string {
+	lines := []string{"status: " + p.Status}
+	if p.ContentType != "" {
+		lines = append(lines, "type: "+p.ContentType)
+	}
+	if p.Length >= 0 {
+		lines = append(lines, "size: "+formatSize(p.Length))
+	}
+	if p.FinalURL != rawURL {
+		lines = append(lines, "redirects to: "+p.FinalURL)
+	}
+
+	return lines
+}
+
+// peekURL fetches the response headers of the URL, following redirects
+func peekURL(client *http.Client, rawURL string) (*peekInfo, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, u.String(), http.NoBody)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+		// the body of GET requests is never read, only the headers
+		resp, err = client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching headers: %w", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+
+	return &peekInfo{
+		Status:      resp.Status,
+		ContentType: resp.Header.Get("Content-Type"),
+		FinalURL:    resp.Request.URL.String(),
+		Length:      resp.ContentLength,
+	}, nil
+}
+
+// peekSelection shows the response headers of the selected URLs and asks
+// whether to proceed
+func peekSelection(items []Match) bool {
+	client := newHTTPClient(peekTimeout)
+
+	var lines []string
+	for i := range items {
+		if !checkable(&items[i]) {
+			continue
+		}
+
+		url := items[i].Value
+		info, err := peekURL(client, url)
+		if err != nil {
+			lines = append(lines, url+": "+err.Error())
+			continue
+		}
+		for _, l := range info.lines(url) {
+			if len(items) > 1 {
+				l = url + ": " + l
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	if len(lines) == 0 {
+		return true
+	}
+
+	fmt.Fprintln(os.Stderr, strings.Join(lines, "\n"))
+
+	// anything but yes cancels, including the information lines
+	m := newMenu("Proceed?")
+	output, err := m.show("no\nyes\n" + strings.Join(lines, "\n"))
+	if errors.Is(err, errMenuCanceled) {
+		return false
+	}
+	logErrAndExit(err)
+
+	return strings.TrimSpace(output) == "yes"
+}
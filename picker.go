@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// builtin is the picker drawn on the terminal by gourl itself, for when
+// there is no menu installed or to show previews of the links
+var builtin = Menu{
+	Command:   "builtin",
+	MultiArgs: []string{"-m"},
+	Run:       runPicker,
+}
+
+var (
+	errMenuCanceled = errors.New("menu canceled")
+	errNoTerminal   = errors.New("the builtin menu needs a terminal")
+)
+
+// terminal escape sequences used by the picker
+const (
+	escAltScreen  = "\x1b[?1049h\x1b[?25l"
+	escMainScreen = "\x1b[?25h\x1b[?1049l"
+	escClear      = "\x1b[H\x1b[2J"
+	escReverse    = "\x1b[7m"
+	escBold       = "\x1b[1m"
+	escReset      = "\x1b[0m"
+)
+
+// picker is the state of the builtin menu
+type picker struct {
+	tty    *os.File
+	prompt string
+	multi  bool
+	items  []string
+	query  []rune
+	// matches are the indexes of the items matching the query
+	matches []int
+	cursor  int
+	offset  int
+	marked  map[int]bool
+	rows    int
+	cols    int
+	preview *previewer
+}
+
+// stty runs stty on the terminal and returns its output
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error setting up terminal: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// terminalSize returns the rows and columns of the terminal
+func terminalSize(tty *os.File) (int, int) {
+	rows, cols := 24, 80
+	out, err := stty(tty, "size")
+	if err != nil {
+		return rows, cols
+	}
+
+	if f := strings.Fields(out); len(f) == 2 {
+		if n, err := strconv.Atoi(f[0]); err == nil && n > 0 {
+			rows = n
+		}
+		if n, err := strconv.Atoi(f[1]); err == nil && n > 0 {
+			cols = n
+		}
+	}
+
+	return rows, cols
+}
+
+// runPicker shows the lines of s in the builtin menu and returns the
+// selected ones. It takes the dmenu -p prompt and -m for multiple selection.
+func runPicker(m *Menu, s string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errNoTerminal, err)
+	}
+	defer tty.Close()
+
+	state, err := stty(tty, "-g")
+	if err != nil {
+		return "", err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return "", err
+	}
+	defer stty(tty, state)
+
+	p := &picker{tty: tty, marked: make(map[int]bool)}
+	if s != "" {
+		p.items = strings.Split(s, "\n")
+	}
+	for i := 0; i < len(m.Arguments); i++ {
+		switch m.Arguments[i] {
+		case "-p":
+			if i+1 < len(m.Arguments) {
+				i++
+				p.prompt = m.Arguments[i]
+			}
+		case "-m":
+			p.multi = true
+		}
+	}
+	if mode := previewMode(); mode != previewNone {
+		p.preview = newPreviewer(mode)
+	}
+
+	fmt.Fprint(tty, escAltScreen)
+	defer fmt.Fprint(tty, escMainScreen)
+
+	return p.run()
+}
+
+// run reads keys until an item is chosen or the menu is canceled
+func (p *picker) run() (string, error) {
+	// done stops the key reader when the menu is closed, the read it is
+	// blocked on ends when the terminal is closed
+	keys, done := make(chan []byte), make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := p.tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			select {
+			case keys <- buf[:n]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var previews <-chan string
+	if p.preview != nil {
+		previews = p.preview.done
+	}
+
+	p.filter()
+	for {
+		p.draw()
+
+		select {
+		case <-previews:
+		case key, ok := <-keys:
+			if !ok {
+				return "", errMenuCanceled
+			}
+			if out, done, err := p.handleKey(key); done {
+				return out, err
+			}
+		}
+	}
+}
+
+// handleKey updates the state with the key, done is set when the menu
+// must be closed
+func (p *picker) handleKey(key []byte) (string, bool, error) {
+	switch k := string(key); k {
+	case "\x1b", "\x03", "\x07":
+		return "", true, errMenuCanceled
+	case "\r", "\n":
+		return p.selection(), true, nil
+	case "\x1b[A", "\x1bOA", "\x10", "\x0b":
+		p.move(-1)
+	case "\x1b[B", "\x1bOB", "\x0e":
+		p.move(1)
+	case "\x1b[5~":
+		p.move(-p.listRows())
+	case "\x1b[6~":
+		p.move(p.listRows())
+	case "\t":
+		if p.multi && len(p.matches) > 0 {
+			i := p.matches[p.cursor]
+			p.marked[i] = !p.marked[i]
+			p.move(1)
+		}
+	case "\x7f", "\x08":
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		}
+	case "\x15":
+		p.query = p.query[:0]
+		p.filter()
+	default:
+		if strings.HasPrefix(k, "\x1b") {
+			break
+		}
+		for _, r := range k {
+			if unicode.IsPrint(r) {
+				p.query = append(p.query, r)
+			}
+		}
+		p.filter()
+	}
+
+	return "", false, nil
+}
+
+// selection returns the marked items, or the one under the cursor. The
+// query is returned when nothing matches, like dmenu does.
+func (p *picker) selection() string {
+	var lines []string
+	for i, item := range p.items {
+		if p.marked[i] {
+			lines = append(lines, item)
+		}
+	}
+	if len(lines) > 0 {
+		return strings.Join(lines, "\n")
+	}
+
+	if len(p.matches) == 0 {
+		return string(p.query)
+	}
+
+	return p.items[p.matches[p.cursor]]
+}
+
+// filter keeps the items containing every word of the query, ignoring case
+func (p *picker) filter() {
+	words := strings.Fields(strings.ToLower(string(p.query)))
+	p.matches = p.matches[:0]
+	for i, item := range p.items {
+		text := strings.ToLower(ansiEscape.ReplaceAllString(item, ""))
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			p.matches = append(p.matches, i)
+		}
+	}
+
+	p.cursor, p.offset = 0, 0
+}
+
+// move moves the cursor by n items, scrolling the list when needed
+func (p *picker) move(n int) {
+	if len(p.matches) == 0 {
+		return
+	}
+
+	p.cursor = max(0, min(len(p.matches)-1, p.cursor+n))
+	rows := p.listRows()
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+}
+
+// listRows returns the number of items shown at once
+func (p *picker) listRows() int {
+	return max(1, p.rows-1)
+}
+
+// truncate cuts the text to n columns
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+
+	return string(r[:n-1]) + "…"
+}
+
+// pickerURL finds the URL in an item of the menu, which can have an index,
+// a label or its origin around it
+var pickerURL = regexp.MustCompile(urlRegex)
+
+// draw renders the prompt, the visible items and the preview of the item
+// under the cursor
+func (p *picker) draw() {
+	p.rows, p.cols = terminalSize(p.tty)
+	width := p.cols
+	if p.preview != nil {
+		width = p.cols / 2
+	}
+
+	var b strings.Builder
+	b.WriteString(escClear)
+	if p.prompt != "" {
+		b.WriteString(escBold + p.prompt + escReset + " ")
+	}
+	b.WriteString(string(p.query))
+	fmt.Fprintf(&b, "  %d/%d", len(p.matches), len(p.items))
+
+	rows := p.listRows()
+	for row := 0; row < rows && p.offset+row < len(p.matches); row++ {
+		i := p.matches[p.offset+row]
+		mark := "  "
+		if p.marked[i] {
+			mark = "* "
+		}
+		text := truncate(ansiEscape.ReplaceAllString(p.items[i], ""), width-len(mark)-1)
+		fmt.Fprintf(&b, "\x1b[%d;1H%s", row+2, mark)
+		if p.offset+row == p.cursor {
+			b.WriteString(escReverse + text + escReset)
+			continue
+		}
+		b.WriteString(text)
+	}
+
+	if p.preview != nil {
+		p.preview.clear(&b)
+		if len(p.matches) > 0 {
+			item := ansiEscape.ReplaceAllString(p.items[p.matches[p.cursor]], "")
+			if u := pickerURL.FindString(item); u != "" {
+				p.preview.draw(&b, u, 2, width+2, rows, p.cols-width-2)
+			}
+		}
+	}
+
+	fmt.Fprint(p.tty, b.String())
+}
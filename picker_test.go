@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestPickerRunStopsReader checks the key reader does not block forever
+// sending the keys read after the menu is closed
+func TestPickerRunStopsReader(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	before := runtime.NumGoroutine()
+
+	p := &picker{tty: r, items: []string{"https://a.example", "https://b.example"}, marked: make(map[int]bool)}
+	result := make(chan string, 1)
+	go func() {
+		out, _ := p.run()
+		result <- out
+	}()
+
+	for _, key := range []string{"b", "\r", "late key"} {
+		if _, err := w.WriteString(key); err != nil {
+			t.Fatal(err)
+		}
+		// one read for each key
+		time.Sleep(50 * time.Millisecond)
+	}
+	select {
+	case out := <-result:
+		if out != "https://b.example" {
+			t.Errorf("got %q, want https://b.example", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the menu did not close")
+	}
+
+	// the reader reads the late key and stops instead of blocking on it
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running", n-before)
+	}
+	r.Close()
+}
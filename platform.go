@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// dmenu is the default menu on unix-like systems
+var dmenu = Menu{
+	Command: "dmenu",
+	Arguments: []string{
+		"-i",
+		"-l", "10",
+	},
+}
+
+// fzf is the menu used on Windows and Termux when it is found in PATH
+var fzf = Menu{
+	Command: "fzf",
+	Arguments: []string{
+		"--no-sort",
+		"--layout=reverse",
+		"--ansi",
+	},
+	PromptArgs: func(s string) []string {
+		return []string{"--prompt=" + s + " "}
+	},
+	Dim: func(s string) string {
+		return "\x1b[2m" + s + "\x1b[0m"
+	},
+	MultiArgs: []string{"--multi"},
+	Streams:   true,
+}
+
+// rofi is used in dmenu mode, with -show-icons it shows the favicon of each
+// link
+var rofi = Menu{
+	Command: "rofi",
+	Arguments: []string{
+		"-dmenu",
+		"-i",
+	},
+	MultiArgs: []string{"-multi-select"},
+	Icon: func(s, path string) string {
+		return s + "\x00icon\x1f" + path
+	},
+	Streams: true,
+}
+
+// choose is the native menu on macOS, it takes the prompt with -p like
+// dmenu
+var choose = Menu{
+	Command: "choose",
+}
+
+// outGridView is the fallback menu on Windows, it reads the items from STDIN
+// and shows them in a PowerShell grid window
+var outGridView = Menu{
+	Command: "powershell.exe",
+	Arguments: []string{
+		"-NoProfile",
+	},
+	PromptArgs: func(s string) []string {
+		title := strings.ReplaceAll(s, "'", "''")
+		return []string{
+			"-Command",
+			"$input | Out-GridView -OutputMode Single -Title '" + title + "'",
+		}
+	},
+}
+
+// menus are the known menus by command name
+var menus = map[string]Menu{
+	dmenu.Command:   dmenu,
+	fzf.Command:     fzf,
+	choose.Command:  choose,
+	rofi.Command:    rofi,
+	builtin.Command: builtin,
+}
+
+// menuFromCommand returns the menu for the command line, known menus keep
+// their settings and get the extra arguments. Plugins, gourl-<name>, get
+// the lines as JSON and other commands are expected to work like dmenu.
+func menuFromCommand(s string) Menu {
+	fields := commandArgs(s)
+	if len(fields) == 0 {
+		return platform.Menu
+	}
+
+	m, ok := menus[fields[0]]
+	switch {
+	case ok:
+	case strings.HasPrefix(fields[0], pluginPrefix):
+		m = Menu{Command: fields[0], MultiArgs: []string{"-m"}, Run: runPluginPicker}
+	default:
+		m = Menu{Command: fields[0]}
+	}
+	m.Arguments = append(append([]string(nil), m.Arguments...), fields[1:]...)
+
+	return m
+}
+
+// Platform holds the commands used to interact with the system
+type Platform struct {
+	Name string
+	// Opener is the command used to open URLs
+	Opener string
+	// Copy is the command that reads from STDIN and sets the clipboard, when
+	// empty the clipboard package is used
+	Copy []string
+	// Paste is the command that prints the clipboard content, when empty the
+	// clipboard package is used
+	Paste []string
+	// Notify is the command used to send notifications, the message is
+	// appended as the last argument
+	Notify []string
+	Menu   Menu
+}
+
+var (
+	linux = Platform{
+		Name:   "linux",
+		Opener: "xdg-open",
+		Menu:   dmenu,
+	}
+
+	windows = Platform{
+		Name:   "windows",
+		Opener: "cmd",
+		Menu:   outGridView,
+	}
+
+	macos = Platform{
+		Name:   "macos",
+		Opener: "open",
+		Copy:   []string{"pbcopy"},
+		Paste:  []string{"pbpaste"},
+		// the message is passed to the script as its argument, so it needs
+		// no quoting
+		Notify: []string{
+			"osascript",
+			"-e", "on run argv",
+			"-e", `display notification (item 1 of argv) with title "` + appName + `"`,
+			"-e", "end run",
+		},
+		Menu: choose,
+	}
+
+	termux = Platform{
+		Name:   "termux",
+		Opener: "termux-open-url",
+		Copy:   []string{"termux-clipboard-set"},
+		Paste:  []string{"termux-clipboard-get"},
+		Notify: []string{"termux-notification", "--title", appName, "--content"},
+		Menu:   fzf,
+	}
+)
+
+// isTermux reports whether we are running under Termux on Android
+func isTermux() bool {
+	return runtime.GOOS == "android" ||
+		os.Getenv("TERMUX_VERSION") != "" ||
+		strings.Contains(os.Getenv("PREFIX"), "com.termux")
+}
+
+// detectPlatform returns the platform profile for the current system
+func detectPlatform() Platform {
+	var p Platform
+	switch {
+	case runtime.GOOS == "windows":
+		p = windows
+	case runtime.GOOS == "darwin":
+		p = macos
+		if _, err := exec.LookPath(choose.Command); err == nil {
+			return p
+		}
+	case isTermux():
+		p = termux
+	default:
+		return linux
+	}
+
+	if p.Menu.Command != fzf.Command {
+		if _, err := exec.LookPath(fzf.Command); err == nil {
+			p.Menu = fzf
+		}
+	}
+
+	return p
+}
+
+// platform is the profile detected for the current system
+var platform = detectPlatform()
+
+// cmdEscaper escapes the characters that cmd.exe treats as special, the
+// quotes and blanks, which cmd.exe would take as the end of the URL, are
+// percent-encoded
+var cmdEscaper = strings.NewReplacer(
+	`"`, "^%22",
+	" ", "^%20",
+	"\t", "^%09",
+	"^", "^^",
+	"&", "^&",
+	"|", "^|",
+	"<", "^<",
+	">", "^>",
+	"(", "^(",
+	")", "^)",
+	"%", "^%",
+)
+
+// openWithCmd returns the command set in the config for the scheme of the
+// URL, nil when there is none. On macOS the value is the name of the app
+// that opens it, with 'open -a'.
+func openWithCmd(rawURL string) *exec.Cmd {
+	if len(config.OpenWith) == 0 {
+		return nil
+	}
+
+	scheme, _, found := strings.Cut(rawURL, ":")
+	if !found {
+		return nil
+	}
+
+	with, ok := config.OpenWith[strings.ToLower(scheme)]
+	if !ok || strings.TrimSpace(with) == "" {
+		return nil
+	}
+
+	if platform.Name == macos.Name {
+		return exec.Command("open", "-a", with, rawURL)
+	}
+
+	args := append(commandArgs(with), rawURL)
+	return exec.Command(args[0], args[1:]...)
+}
+
+// startArgs returns the cmd.exe command line that opens the URL with
+// start, a builtin of cmd.exe. The URL is escaped so '&' in a query string
+// is not taken as a command separator, and the empty title keeps start
+// from taking the first quoted argument as the title.
+func startArgs(url string) []string {
+	return []string{"cmd", "/c", "start", `""`, cmdEscaper.Replace(url)}
+}
+
+// openCmd returns the command that opens the URL
+func openCmd(url string) *exec.Cmd {
+	if xdgOpen == "cmd" {
+		return rawCommand(startArgs(url)...)
+	}
+
+	return exec.Command(xdgOpen, url)
+}
+
+// browserCmd returns the command from $BROWSER that opens the URL, nil when
+// it is not set or none of its commands is found. $BROWSER is a list of
+// commands separated by colons, '%s' is replaced with the URL or else it is
+// appended.
+func browserCmd(url string) *exec.Cmd {
+	for _, entry := range filepath.SplitList(os.Getenv("BROWSER")) {
+		args := commandArgs(entry)
+		if len(args) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			log.Printf("$BROWSER: %s", err)
+			continue
+		}
+
+		var substituted bool
+		for i, arg := range args[1:] {
+			if strings.Contains(arg, "%s") {
+				arg = strings.ReplaceAll(arg, "%s", url)
+				substituted = true
+			}
+			args[i+1] = strings.ReplaceAll(arg, "%%", "%")
+		}
+		if !substituted {
+			args = append(args, url)
+		}
+
+		return exec.Command(args[0], args[1:]...)
+	}
+
+	return nil
+}
+
+// copyCmd sets the clipboard with the platform copy command
+func copyCmd(s string) error {
+	cmd := exec.Command(platform.Copy[0], platform.Copy[1:]...)
+	cmd.Stdin = strings.NewReader(s)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", platform.Copy[0], err)
+	}
+
+	return nil
+}
+
+// pasteCmd returns the clipboard content using the platform paste command
+func pasteCmd() (string, error) {
+	output, err := exec.Command(platform.Paste[0], platform.Paste[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", platform.Paste[0], err)
+	}
+
+	return string(output), nil
+}
+
+// notify sends a notification if the platform supports it
+func notify(msg string) {
+	if len(platform.Notify) == 0 {
+		return
+	}
+
+	args := append(platform.Notify[1:len(platform.Notify):len(platform.Notify)], msg)
+	if err := exec.Command(platform.Notify[0], args...).Run(); err != nil {
+		log.Printf("error sending notification: %s", err)
+	}
+}
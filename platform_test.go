@@ -0,0 +1,24 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStartArgs(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://a.example/page", "https://a.example/page"},
+		{"https://a.example/?a=1&b=2", "https://a.example/?a=1^&b=2"},
+		{"https://a.example/%41|(x)<y>^z", "https://a.example/^%41^|^(x^)^<y^>^^z"},
+		{`https://a.example/"quoted" path`, "https://a.example/^%22quoted^%22^%20path"},
+		{"https://a.example/%PATH%", "https://a.example/^%PATH^%"},
+	}
+	for _, tt := range tests {
+		want := []string{"cmd", "/c", "start", `""`, tt.want}
+		if got := startArgs(tt.url); !reflect.DeepEqual(got, want) {
+			t.Errorf("startArgs(%q): got %q, want %q", tt.url, got, want)
+		}
+	}
+}
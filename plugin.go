@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pluginPrefix is the prefix of the plugin executables, gourl-<name>
+const pluginPrefix = "gourl-"
+
+// pluginEnv tells the plugin how it is run: command, action, picker or
+// source
+const pluginEnv = "GOURL_PLUGIN"
+
+// plugin roles
+const (
+	roleCommand = "command"
+	roleAction  = "action"
+	rolePicker  = "picker"
+	roleSource  = "source"
+)
+
+// pluginItem is an item as the plugins get it
+type pluginItem struct {
+	URL    string `json:"url"`
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"`
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Count  int    `json:"count"`
+}
+
+// pluginItems returns the JSON array of the items given to the plugins
+func pluginItems(items []Match) ([]byte, error) {
+	out := make([]pluginItem, 0, len(items))
+	for i := range items {
+		m := &items[i]
+		out = append(out, pluginItem{
+			URL:    m.Value,
+			Type:   m.Type,
+			Label:  m.Label,
+			Source: m.Source,
+			Line:   m.Line,
+			Count:  m.Count,
+		})
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding items: %w", err)
+	}
+
+	return b, nil
+}
+
+// findPlugin returns the path of the plugin with the name
+func findPlugin(name string) (string, error) {
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s%s", errMissingCmd, pluginPrefix, name)
+	}
+
+	return path, nil
+}
+
+// pluginCmd returns the command running the plugin in the role
+func pluginCmd(ctx context.Context, path, role string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = append(os.Environ(), pluginEnv+"="+role, "GOURL_VERSION="+appVersion)
+	cmd.Stderr = os.Stderr
+
+	return cmd
+}
+
+// plugins returns the plugins found in $PATH by name, the first one of
+// each name wins like in the shell
+func plugins() map[string]string {
+	found := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), pluginPrefix)
+			if !ok || name == "" || found[name] != "" {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() && fi.Mode()&0o111 != 0 {
+				found[name] = path
+			}
+		}
+	}
+
+	return found
+}
+
+// runPlugins lists the plugins found in $PATH
+func runPlugins(_ *Options, _ []string) error {
+	found := plugins()
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name, found[name])
+	}
+
+	return nil
+}
+
+// pluginFor returns the plugin run as 'gourl <name>', files in the current
+// directory with the same name are read as input instead
+func pluginFor(name string) (string, bool) {
+	if name == "" || name == "-" || strings.ContainsRune(name, os.PathSeparator) {
+		return "", false
+	}
+	if _, err := os.Stat(name); err == nil {
+		return "", false
+	}
+
+	path, err := findPlugin(name)
+	if err != nil {
+		return "", false
+	}
+
+	return path, true
+}
+
+// runPluginCommand runs the plugin with the items found in STDIN as JSON in
+// its STDIN, and the arguments after its name
+func runPluginCommand(o *Options, path string, args []string) error {
+	var items []Match
+	if stdinPiped() {
+		sources, err := o.prepareInput("stdin", os.Stdin)
+		if err != nil {
+			return err
+		}
+		if items, err = o.extractItems(sources, nil); err != nil && !errors.Is(err, errNoURLFound) {
+			return err
+		}
+		items = uniqueItems(items)
+	}
+
+	b, err := pluginItems(items)
+	if err != nil {
+		return err
+	}
+
+	cmd := pluginCmd(context.Background(), path, roleCommand, args...)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = os.Stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+// pluginAction is an action run by a plugin, it gets the selected items as
+// JSON in its STDIN
+type pluginAction struct {
+	name string
+	path string
+}
+
+func (a *pluginAction) Name() string { return a.name }
+
+func (a *pluginAction) Multi() bool { return true }
+
+func (a *pluginAction) Run(ctx context.Context, _ *Options, items []Match) error {
+	for i := range items {
+		if err := checkSafeURL(items[i].Value); err != nil {
+			return fmt.Errorf("action %q: %w", a.name, err)
+		}
+	}
+
+	b, err := pluginItems(items)
+	if err != nil {
+		return err
+	}
+
+	cmd := pluginCmd(ctx, a.path, roleAction)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = os.Stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("action %q: %w", a.name, err)
+	}
+
+	return nil
+}
+
+// runPluginPicker shows the lines in the picker plugin, which gets them as
+// a JSON array in its STDIN with the menu arguments, -p prompt and -m for
+// multiple selection, and prints the selected lines. Like the menus, it
+// exits with 1 or 130 without output when canceled.
+func runPluginPicker(m *Menu, s string) (string, error) {
+	lines := []string{}
+	if s != "" {
+		lines = strings.Split(s, "\n")
+	}
+	b, err := json.Marshal(lines)
+	if err != nil {
+		return "", fmt.Errorf("error encoding items: %w", err)
+	}
+
+	cmd := pluginCmd(context.Background(), m.Command, rolePicker, m.Arguments...)
+	cmd.Stdin = bytes.NewReader(b)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	logMenuStderr(stderr.String())
+	if err != nil {
+		return "", menuError(m.Command, err, len(out) == 0, stderr.String())
+	}
+
+	return string(out), nil
+}
+
+// readPluginSource returns the output of the source plugin, used as input
+func readPluginSource(name string) (io.Reader, error) {
+	path, err := findPlugin(name)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := pluginCmd(context.Background(), path, roleSource).Output()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+
+	return bytes.NewReader(out), nil
+}
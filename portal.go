@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// special openers that do not spawn xdg-open
+const (
+	openerPortal = "portal"
+	openerGio    = "gio"
+)
+
+// XDG Desktop Portal OpenURI interface
+const (
+	portalDest   = "org.freedesktop.portal.Desktop"
+	portalPath   = "/org/freedesktop/portal/desktop"
+	portalIface  = "org.freedesktop.portal.OpenURI"
+	portalMethod = "OpenURI"
+)
+
+// gvariantString quotes s as a GVariant text string
+func gvariantString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// portalCmd returns the command that asks the desktop portal to open the
+// URL, using gdbus or busctl
+func portalCmd(url string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		return exec.Command("gdbus", "call", "--session",
+			"--dest", portalDest,
+			"--object-path", portalPath,
+			"--method", portalIface+"."+portalMethod,
+			"''", gvariantString(url), "{}",
+		), nil
+	}
+
+	if _, err := exec.LookPath("busctl"); err == nil {
+		return exec.Command("busctl", "--user", "call",
+			portalDest, portalPath, portalIface, portalMethod,
+			"ssa{sv}", "", url, "0",
+		), nil
+	}
+
+	return nil, fmt.Errorf("%w: gdbus or busctl", errMissingCmd)
+}
+
+// openSync opens the URL with the desktop portal or gio and waits for the
+// result, so failures are reported instead of lost in a detached process
+func openSync(url string) error {
+	var cmd *exec.Cmd
+	switch xdgOpen {
+	case openerPortal:
+		var err error
+		if cmd, err = portalCmd(url); err != nil {
+			return fmt.Errorf("error opening URL: %w", err)
+		}
+	case openerGio:
+		cmd = exec.Command("gio", "open", url)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("error opening URL with %s: %w", xdgOpen, err)
+	}
+
+	return nil
+}
+
+// isSyncOpener reports whether the opener waits for the result
+func isSyncOpener(opener string) bool {
+	return opener == openerPortal || opener == openerGio
+}
+
+// --portal modes
+const (
+	portalNever  = "never"
+	portalAuto   = "auto"
+	portalAlways = "always"
+)
+
+var errPortalMode = errors.New("unknown portal mode")
+
+// sandbox returns the name of the sandbox we are running in, if any
+func sandbox() string {
+	if os.Getenv("FLATPAK_ID") != "" {
+		return "flatpak"
+	}
+	if _, err := os.Stat("/.flatpak-info"); err == nil {
+		return "flatpak"
+	}
+	if os.Getenv("SNAP") != "" {
+		return "snap"
+	}
+
+	return ""
+}
+
+// flagSet reports whether the flag was given in the command line
+func flagSet(names ...string) bool {
+	var found bool
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			if f.Name == name {
+				found = true
+			}
+		}
+	})
+
+	return found
+}
+
+// hostClipboardCmd returns the command that sets the clipboard of the host
+// from inside a Flatpak sandbox
+func hostClipboardCmd() []string {
+	host := []string{"flatpak-spawn", "--host"}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return append(host, "wl-copy")
+	}
+
+	return append(host, "xclip", "-in", "-selection", "clipboard")
+}
+
+// applyPortalMode routes the open and clipboard actions through
+// sandbox-safe mechanisms according to --portal
+func applyPortalMode(mode string) error {
+	switch mode {
+	case portalNever:
+		return nil
+	case portalAuto:
+		if sandbox() == "" {
+			return nil
+		}
+	case portalAlways:
+	default:
+		return fmt.Errorf("%w: %q (valid: never, auto, always)", errPortalMode, mode)
+	}
+
+	log.Printf("portal mode: %s, sandbox: %q", mode, sandbox())
+	if !flagSet("opener") {
+		xdgOpen = openerPortal
+	}
+
+	// the clipboard tools are not available inside Flatpak, use the host ones
+	if sandbox() == "flatpak" && len(platform.Copy) == 0 {
+		if _, err := exec.LookPath("flatpak-spawn"); err == nil {
+			platform.Copy = hostClipboardCmd()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readDomains returns the domains of the file, one per line, skipping
+// blank lines and # comments
+func readDomains(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading domains: %w", err)
+	}
+	defer f.Close()
+
+	var domains []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if d := strings.Trim(strings.ToLower(strings.TrimSpace(line)), "."); d != "" {
+			domains = append(domains, strings.TrimPrefix(d, "*."))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// inDomains reports whether the host is one of the domains or their
+// subdomains
+func inDomains(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preferDomains moves the items with hosts in the domains to the top,
+// keeping the order of both groups
+func preferDomains(items []Match, domains []string) []Match {
+	result := make([]Match, 0, len(items))
+	var rest []Match
+	for _, m := range items {
+		if inDomains(itemHost(m.Value), domains) {
+			result = append(result, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+
+	return append(result, rest...)
+}
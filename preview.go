@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // decode gif previews
+	_ "image/jpeg" // decode jpeg previews
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// image protocols of --preview, auto detects them from the terminal
+const (
+	previewNone  = "none"
+	previewAuto  = "auto"
+	previewKitty = "kitty"
+	previewSixel = "sixel"
+)
+
+var previewModes = []string{previewNone, previewAuto, previewKitty, previewSixel}
+
+const (
+	previewTimeout  = 10 * time.Second
+	previewMaxPage  = 1 << 20
+	previewMaxImage = 4 << 20
+	// previewSize is the largest side of the cached thumbnails, in pixels
+	previewSize = 256
+	// cellWidth and cellHeight are the assumed size of a terminal cell in
+	// pixels, used to fit the images in the preview area
+	cellWidth  = 10
+	cellHeight = 20
+)
+
+var (
+	errNoPreview     = errors.New("no preview image")
+	errImageFormat   = errors.New("unsupported image format")
+	errPreviewScheme = errors.New("preview needs an http or https URL")
+)
+
+var (
+	previewMeta = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	previewLink = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	htmlAttrs   = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	pngMagic    = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// validatePreview checks the value of the --preview flag
+func validatePreview(s string) error {
+	if !inList(previewModes, s) {
+		return fmt.Errorf("%w: --preview %q (valid: %s)", errInvalidFlag, s, strings.Join(previewModes, ", "))
+	}
+
+	return nil
+}
+
+// previewMode returns the image protocol used for previews, resolving auto
+// from the terminal
+func previewMode() string {
+	if previewFlag != previewAuto {
+		return previewFlag
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", strings.Contains(term, "kitty"),
+		os.Getenv("TERM_PROGRAM") == "WezTerm", strings.Contains(term, "ghostty"):
+		return previewKitty
+	case strings.Contains(term, "foot"), strings.Contains(term, "mlterm"),
+		strings.Contains(term, "contour"), strings.Contains(term, "sixel"):
+		return previewSixel
+	}
+
+	return previewNone
+}
+
+// htmlAttr returns the value of the attribute of the HTML tag
+func htmlAttr(tag, name string) string {
+	for _, m := range htmlAttrs.FindAllStringSubmatch(tag, -1) {
+		if strings.EqualFold(m[1], name) {
+			return m[2] + m[3] + m[4]
+		}
+	}
+
+	return ""
+}
+
+// pageIcon returns the icon of the HTML page or /favicon.ico, the touch
+// icon first since it is usually a larger png
+func pageIcon(page string) string {
+	for _, rel := range []string{"apple-touch-icon", "icon"} {
+		for _, tag := range previewLink.FindAllString(page, -1) {
+			if inList(strings.Fields(strings.ToLower(htmlAttr(tag, "rel"))), rel) {
+				if href := htmlAttr(tag, "href"); href != "" {
+					return href
+				}
+			}
+		}
+	}
+
+	return "/favicon.ico"
+}
+
+// resolveRef returns the reference found in the page resolved against its
+// URL, or "" if it is not valid
+func resolveRef(base *url.URL, ref string) string {
+	u, err := url.Parse(decodeEntities(ref))
+	if err != nil {
+		return ""
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+// previewImageURL returns the image that represents the page: its og:image,
+// its icon or /favicon.ico
+func previewImageURL(base *url.URL, page string) string {
+	for _, tag := range previewMeta.FindAllString(page, -1) {
+		p := strings.ToLower(htmlAttr(tag, "property") + htmlAttr(tag, "name"))
+		if p == "og:image" || p == "twitter:image" {
+			if content := htmlAttr(tag, "content"); content != "" {
+				return resolveRef(base, content)
+			}
+		}
+	}
+
+	return resolveRef(base, pageIcon(page))
+}
+
+// decodeICO decodes the largest image of an ICO file, stored as PNG or as a
+// 32-bit bitmap
+func decodeICO(b []byte) (image.Image, error) {
+	if len(b) < 6 || binary.LittleEndian.Uint16(b[2:]) != 1 {
+		return nil, errImageFormat
+	}
+
+	var best image.Image
+	n := int(binary.LittleEndian.Uint16(b[4:]))
+	for i := 0; i < n && 6+16*(i+1) <= len(b); i++ {
+		e := b[6+16*i:]
+		size := int(binary.LittleEndian.Uint32(e[8:]))
+		off := int(binary.LittleEndian.Uint32(e[12:]))
+		if off < 0 || size < 0 || off+size > len(b) {
+			continue
+		}
+
+		var img image.Image
+		var err error
+		data := b[off : off+size]
+		if bytes.HasPrefix(data, pngMagic) {
+			img, err = png.Decode(bytes.NewReader(data))
+		} else {
+			img, err = decodeICOBitmap(data)
+		}
+		if err != nil {
+			continue
+		}
+		if best == nil || img.Bounds().Dx() > best.Bounds().Dx() {
+			best = img
+		}
+	}
+
+	if best == nil {
+		return nil, errImageFormat
+	}
+
+	return best, nil
+}
+
+// decodeICOBitmap decodes a 32-bit bitmap of an ICO file, its height counts
+// the AND mask too and its rows go from the bottom up
+func decodeICOBitmap(b []byte) (image.Image, error) {
+	const headerSize = 40
+	if len(b) < headerSize || binary.LittleEndian.Uint16(b[14:]) != 32 {
+		return nil, errImageFormat
+	}
+
+	w := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	h := int(int32(binary.LittleEndian.Uint32(b[8:]))) / 2
+	if w <= 0 || h <= 0 || len(b) < headerSize+w*h*4 {
+		return nil, errImageFormat
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	px := b[headerSize:]
+	for y := 0; y < h; y++ {
+		row := px[(h-1-y)*w*4:]
+		for x := 0; x < w; x++ {
+			p := row[x*4:]
+			img.SetNRGBA(x, y, color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]})
+		}
+	}
+
+	return img, nil
+}
+
+// decodeImage decodes a png, jpeg, gif or ico image
+func decodeImage(b []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err == nil {
+		return img, nil
+	}
+
+	return decodeICO(b)
+}
+
+// fitSize returns the size of the image scaled to fit in maxW x maxH
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+
+	return max(1, w*maxH/h), maxH
+}
+
+// scaleImage resizes the image to w x h with the nearest neighbour
+func scaleImage(img image.Image, w, h int) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + x*b.Dx()/w
+			out.Set(x, y, img.At(sx, sy))
+		}
+	}
+
+	return out
+}
+
+// thumbnailPath returns the path of the cached thumbnail for key in the
+// cache subdirectory
+func thumbnailPath(subdir, key string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, subdir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating %s dir: %w", subdir, err)
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".png"), nil
+}
+
+// saveThumbnail decodes the image and writes it to path as a png, scaled
+// down to fit in size x size
+func saveThumbnail(path string, b []byte, size int) error {
+	img, err := decodeImage(b)
+	if err != nil {
+		return fmt.Errorf("error decoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() > size || bounds.Dy() > size {
+		w, h := fitSize(bounds.Dx(), bounds.Dy(), size, size)
+		img = scaleImage(img, w, h)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("error encoding image: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing image: %w", err)
+	}
+
+	return nil
+}
+
+// fetchBody returns the body of the URL, up to limit bytes
+func fetchBody(client *http.Client, rawURL string, limit int64) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, http.NoBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("error fetching %s: %w: %s", rawURL, errHTTPStatus, resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading page: %w", err)
+	}
+
+	return resp, b, nil
+}
+
+// fetchPreview returns the path of the thumbnail of the URL, the page
+// itself if it is an image or else its og:image or icon. Thumbnails are
+// cached.
+func fetchPreview(client *http.Client, rawURL string) (string, error) {
+	path, err := thumbnailPath("previews", rawURL)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errPreviewScheme
+	}
+
+	resp, b, err := fetchBody(client, u.String(), previewMaxPage)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		imgURL := previewImageURL(resp.Request.URL, string(b))
+		if imgURL == "" {
+			return "", errNoPreview
+		}
+		if _, b, err = fetchBody(client, imgURL, previewMaxImage); err != nil {
+			return "", err
+		}
+	}
+
+	if err := saveThumbnail(path, b, previewSize); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// previewer fetches the previews of the picker in the background
+type previewer struct {
+	mode   string
+	client *http.Client
+	// done is signaled when a preview is fetched, to redraw the picker
+	done chan string
+
+	mu      sync.Mutex
+	paths   map[string]string
+	pending map[string]bool
+}
+
+func newPreviewer(mode string) *previewer {
+	return &previewer{
+		mode:    mode,
+		client:  newHTTPClient(previewTimeout),
+		done:    make(chan string, 1),
+		paths:   make(map[string]string),
+		pending: make(map[string]bool),
+	}
+}
+
+// path returns the thumbnail of the URL, starting to fetch it when it is
+// not known yet
+func (pv *previewer) path(rawURL string) string {
+	pv.mu.Lock()
+	defer pv.mu.Unlock()
+
+	if path, ok := pv.paths[rawURL]; ok || pv.pending[rawURL] {
+		return path
+	}
+
+	pv.pending[rawURL] = true
+	go func() {
+		path, err := fetchPreview(pv.client, rawURL)
+		if err != nil {
+			log.Printf("preview of %s: %s", rawURL, err)
+		}
+
+		pv.mu.Lock()
+		pv.paths[rawURL] = path
+		delete(pv.pending, rawURL)
+		pv.mu.Unlock()
+
+		select {
+		case pv.done <- rawURL:
+		default:
+		}
+	}()
+
+	return ""
+}
+
+// clear removes the images drawn before, sixel images are removed with
+// the text
+func (pv *previewer) clear(b *strings.Builder) {
+	if pv.mode == previewKitty {
+		b.WriteString("\x1b_Ga=d,d=A,q=2\x1b\\")
+	}
+}
+
+// draw draws the preview of the URL at row and col, in an area of rows x
+// cols cells
+func (pv *previewer) draw(b *strings.Builder, rawURL string, row, col, rows, cols int) {
+	path := pv.path(rawURL)
+	if path == "" || rows < 1 || cols < 1 {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	w, h := fitSize(img.Bounds().Dx(), img.Bounds().Dy(), cols*cellWidth, rows*cellHeight)
+	fmt.Fprintf(b, "\x1b[%d;%dH", row, col)
+	switch pv.mode {
+	case previewKitty:
+		writeKitty(b, data, max(1, w/cellWidth), max(1, h/cellHeight))
+	case previewSixel:
+		writeSixel(b, scaleImage(img, w, h))
+	}
+}
+
+// writeKitty writes the png image with the kitty graphics protocol, scaled
+// to cols x rows cells
+func writeKitty(b *strings.Builder, data []byte, cols, rows int) {
+	const chunkSize = 4096
+	enc := base64.StdEncoding.EncodeToString(data)
+	for i := 0; i < len(enc); i += chunkSize {
+		chunk := enc[i:min(i+chunkSize, len(enc))]
+		more := 0
+		if i+chunkSize < len(enc) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(b, "\x1b_Ga=T,f=100,q=2,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, chunk)
+			continue
+		}
+		fmt.Fprintf(b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+	}
+}
+
+// writeSixel writes the image as sixels, with the colors reduced to a
+// 6x6x6 cube. Transparent pixels are left empty.
+func writeSixel(b *strings.Builder, img *image.NRGBA) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	idx := make([]int, w*h)
+	used := make(map[int]bool)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				idx[y*w+x] = -1
+				continue
+			}
+			i := sixelLevel(c.R)*36 + sixelLevel(c.G)*6 + sixelLevel(c.B)
+			idx[y*w+x] = i
+			used[i] = true
+		}
+	}
+
+	fmt.Fprintf(b, "\x1bP0;1;0q\"1;1;%d;%d", w, h)
+	for i := range used {
+		fmt.Fprintf(b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	row := make([]byte, w)
+	for y0 := 0; y0 < h; y0 += 6 {
+		for c := range used {
+			found := false
+			for x := 0; x < w; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y0+dy < h; dy++ {
+					if idx[(y0+dy)*w+x] == c {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = 63 + bits
+				found = found || bits != 0
+			}
+			if !found {
+				continue
+			}
+			fmt.Fprintf(b, "#%d", c)
+			writeSixelRun(b, bytes.TrimRight(row, "?"))
+			b.WriteByte('$')
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+}
+
+// sixelLevel returns the nearest of the 6 levels of a color component
+func sixelLevel(v uint8) int {
+	return (int(v)*5 + 127) / 255
+}
+
+// writeSixelRun writes the sixels compressed with repeat counts
+func writeSixelRun(b *strings.Builder, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(b, "!%d%c", n, row[i])
+		} else {
+			b.WriteString(strings.Repeat(string(row[i]), n))
+		}
+		i = j
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// punycode parameters, RFC 3492
+const (
+	pcBase        = 36
+	pcTMin        = 1
+	pcTMax        = 26
+	pcSkew        = 38
+	pcDamp        = 700
+	pcInitialBias = 72
+	pcInitialN    = 128
+)
+
+// pcAdapt is the bias adaptation function of RFC 3492
+func pcAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= pcDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((pcBase-pcTMin)*pcTMax)/2 {
+		delta /= pcBase - pcTMin
+		k += pcBase
+	}
+
+	return k + (pcBase-pcTMin+1)*delta/(delta+pcSkew)
+}
+
+// pcDigit returns the character of a punycode digit
+func pcDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+
+	return byte('0' + d - 26)
+}
+
+// punycodeEncode encodes the label with punycode, without the xn-- prefix
+func punycodeEncode(label string) string {
+	runes := []rune(label)
+	var b strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b.WriteByte(byte(r))
+		}
+	}
+	basic := b.Len()
+	handled := basic
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+
+	n, delta, bias := pcInitialN, 0, pcInitialBias
+	for handled < len(runes) {
+		m := int(utf8.MaxRune)
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+				continue
+			}
+			if int(r) > n {
+				continue
+			}
+
+			q := delta
+			for k := pcBase; ; k += pcBase {
+				t := k - bias
+				if t < pcTMin {
+					t = pcTMin
+				} else if t > pcTMax {
+					t = pcTMax
+				}
+				if q < t {
+					break
+				}
+				b.WriteByte(pcDigit(t + (q-t)%(pcBase-t)))
+				q = (q - t) / (pcBase - t)
+			}
+			b.WriteByte(pcDigit(q))
+			bias = pcAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+
+	return b.String()
+}
+
+// hostToASCII returns the host with its internationalized labels encoded
+// with punycode, like bücher.de to xn--bcher-kva.de
+func hostToASCII(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			labels[i] = "xn--" + punycodeEncode(strings.ToLower(label))
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// isASCII reports whether s only has ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
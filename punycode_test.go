@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+// TestPunycodeEncode checks the sample strings of RFC 3492, section 7.1,
+// the case of the basic characters is kept
+func TestPunycodeEncode(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"arabic", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"chinese simplified", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"chinese traditional", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+		{"czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+		{"hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"russian", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+		{"spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+		{"vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+		{"japanese with digits", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"japanese with latin", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+		{"japanese", "そのスピードで", "d9juau41awczczp"},
+		{"one character", "ü", "tda"},
+		{"ascii", "example", "example-"},
+	}
+	for _, tt := range tests {
+		if got := punycodeEncode(tt.in); got != tt.want {
+			t.Errorf("%s: punycodeEncode(%q): got %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHostToASCII(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"www.München.example", "www.xn--mnchen-3ya.example"},
+		{"ñandú.café.example", "xn--and-6ma2c.xn--caf-dma.example"},
+		{"例え.jp", "xn--r8jz45g.jp"},
+		{"example.com", "example.com"},
+	}
+	for _, tt := range tests {
+		if got := hostToASCII(tt.in); got != tt.want {
+			t.Errorf("hostToASCII(%q): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeIRI(t *testing.T) {
+	tests := []struct {
+		in                      string
+		punycode, percentEncode bool
+		want                    string
+	}{
+		{"https://user@bücher.de:8080/ä?q=ü#ö", true, false, "https://user@xn--bcher-kva.de:8080/ä?q=ü#ö"},
+		{"https://user@bücher.de:8080/ä?q=ü#ö", false, true, "https://user@bücher.de:8080/%C3%A4?q=%C3%BC#%C3%B6"},
+		{"https://user@bücher.de:8080/ä?q=ü#ö", true, true, "https://user@xn--bcher-kva.de:8080/%C3%A4?q=%C3%BC#%C3%B6"},
+		{"www.münchen.example/straße", true, true, "www.xn--mnchen-3ya.example/stra%C3%9Fe"},
+		{"https://example.com/a?b=c", true, true, "https://example.com/a?b=c"},
+	}
+	for _, tt := range tests {
+		if got := normalizeIRI(tt.in, tt.punycode, tt.percentEncode); got != tt.want {
+			t.Errorf("normalizeIRI(%q, %v, %v): got %q, want %q", tt.in, tt.punycode, tt.percentEncode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+// rawCommand returns the command with the arguments, only windows passes
+// a command line to the programs instead of the arguments
+func rawCommand(args ...string) *exec.Cmd {
+	return exec.Command(args[0], args[1:]...)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// rawCommand returns the command with the arguments joined in its command
+// line as they are. cmd.exe does not parse its command line like the
+// programs built with the C runtime, so the arguments escaped for it must
+// not be quoted again by exec.
+func rawCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(args[0])
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: strings.Join(args, " ")}
+
+	return cmd
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+func TestRawCommand(t *testing.T) {
+	cmd := rawCommand(startArgs("https://a.example/?a=1&b=2")...)
+	want := `cmd /c start "" https://a.example/?a=1^&b=2`
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.CmdLine != want {
+		t.Errorf("got %+v, want the command line %s", cmd.SysProcAttr, want)
+	}
+}
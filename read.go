@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	actionRead = "read"
+
+	readTimeout = 30 * time.Second
+	// readMaxDocument is the size limit of the fetched pages
+	readMaxDocument = 10 << 20
+	defaultPager    = "less"
+)
+
+var (
+	// readBoilerplate matches the elements that are not part of the article
+	readBoilerplate = regexp.MustCompile(`(?is)<(script|style|noscript|nav|header|footer|aside|form|svg|iframe|template)\b.*?</(?:script|style|noscript|nav|header|footer|aside|form|svg|iframe|template)>`)
+	readComment     = regexp.MustCompile(`(?s)<!--.*?-->`)
+	readTitle       = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	readArticle     = regexp.MustCompile(`(?is)<article\b[^>]*>(.*?)</article>`)
+	readMain        = regexp.MustCompile(`(?is)<main\b[^>]*>(.*?)</main>`)
+	readBody        = regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body>`)
+	readHeading     = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]>`)
+	readLink        = regexp.MustCompile(`(?is)<a\b[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	readPre         = regexp.MustCompile(`(?is)<pre\b[^>]*>(.*?)</pre>`)
+	readCode        = regexp.MustCompile(`(?is)<code\b[^>]*>(.*?)</code>`)
+	readListItem    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	readBlock       = regexp.MustCompile(`(?i)</?(p|div|section|br|ul|ol|table|tr|blockquote|figure|dl|dd|dt)\b[^>]*>`)
+	readTag         = regexp.MustCompile(`(?s)<[^>]*>`)
+	readSpaces      = regexp.MustCompile(`[ \t\r\f\v]+`)
+	readBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// inlineText returns the text of an HTML fragment in a single line
+func inlineText(s string) string {
+	s = readTag.ReplaceAllString(s, "")
+	return strings.TrimSpace(readSpaces.ReplaceAllString(strings.ReplaceAll(html.UnescapeString(s), "\n", " "), " "))
+}
+
+// extractArticle returns the title and the main content of the HTML page as
+// Markdown, dropping navigation, scripts and other boilerplate
+func extractArticle(page string) (title, text string) {
+	if m := readTitle.FindStringSubmatch(page); m != nil {
+		title = inlineText(m[1])
+	}
+
+	page = readComment.ReplaceAllString(page, "")
+	page = readBoilerplate.ReplaceAllString(page, "")
+
+	// the longest article wins, pages list related posts as articles too
+	content := page
+	if all := readArticle.FindAllStringSubmatch(page, -1); all != nil {
+		content = all[0][1]
+		for _, m := range all[1:] {
+			if len(m[1]) > len(content) {
+				content = m[1]
+			}
+		}
+	} else if m := readMain.FindStringSubmatch(page); m != nil {
+		content = m[1]
+	} else if m := readBody.FindStringSubmatch(page); m != nil {
+		content = m[1]
+	}
+
+	// keep preformatted text away from the whitespace cleanup
+	var pre []string
+	content = readPre.ReplaceAllStringFunc(content, func(s string) string {
+		code := readTag.ReplaceAllString(readPre.FindStringSubmatch(s)[1], "")
+		pre = append(pre, "```\n"+strings.Trim(html.UnescapeString(code), "\n")+"\n```")
+		return fmt.Sprintf("\n\x00%d\x00\n", len(pre)-1)
+	})
+
+	content = readHeading.ReplaceAllStringFunc(content, func(s string) string {
+		m := readHeading.FindStringSubmatch(s)
+		return "\n\n" + strings.Repeat("#", int(m[1][0]-'0')) + " " + inlineText(m[2]) + "\n\n"
+	})
+	content = readLink.ReplaceAllStringFunc(content, func(s string) string {
+		m := readLink.FindStringSubmatch(s)
+		text := inlineText(m[2])
+		if text == "" || strings.HasPrefix(m[1], "#") || strings.HasPrefix(m[1], "javascript:") {
+			return text
+		}
+		return "[" + text + "](" + html.UnescapeString(m[1]) + ")"
+	})
+	content = readCode.ReplaceAllString(content, "`$1`")
+	content = readListItem.ReplaceAllString(content, "\n- ")
+	content = readBlock.ReplaceAllString(content, "\n\n")
+	content = readTag.ReplaceAllString(content, "")
+	content = html.UnescapeString(content)
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(readSpaces.ReplaceAllString(line, " "))
+	}
+	content = strings.Join(lines, "\n")
+	content = readBlankLines.ReplaceAllString(content, "\n\n")
+
+	for i, p := range pre {
+		content = strings.Replace(content, fmt.Sprintf("\x00%d\x00", i), p, 1)
+	}
+
+	return title, strings.TrimSpace(content)
+}
+
+// fetchArticle fetches the URL and returns its readable text as Markdown
+func fetchArticle(client *http.Client, rawURL string) (string, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching page: %w: %s", errHTTPStatus, resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, readMaxDocument))
+	if err != nil {
+		return "", fmt.Errorf("error reading page: %w", err)
+	}
+
+	if !isHTML(resp) {
+		return string(b), nil
+	}
+
+	title, text := extractArticle(string(b))
+	if title == "" {
+		title = rawURL
+	}
+
+	return fmt.Sprintf("# %s\n\n<%s>\n\n%s\n", title, rawURL, text), nil
+}
+
+// pageText writes the text to --read-output, to STDOUT when it is not a
+// terminal or to $PAGER
+func pageText(text string) error {
+	if readOutputFlag != "" {
+		if err := os.WriteFile(readOutputFlag, []byte(text), 0o644); err != nil {
+			return fmt.Errorf("error writing article: %w", err)
+		}
+		return nil
+	}
+
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+
+	pager := commandArgs(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{defaultPager}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running pager: %w", err)
+	}
+
+	return nil
+}
+
+// readAction shows the readable text of the selected pages
+type readAction struct{}
+
+func (readAction) Name() string { return actionRead }
+
+func (readAction) Run(_ context.Context, _ *Options, items []Match) error {
+	client := newHTTPClient(readTimeout)
+	var buf bytes.Buffer
+	for i := range items {
+		text, err := fetchArticle(client, items[i].Value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteString("\n---\n\n")
+		}
+		buf.WriteString(text)
+	}
+
+	return pageText(buf.String())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// defDot is a defanged dot
+	defDot = `(?:\[\.\]|\(\.\)|\{\.\}|\[dot\]|\(dot\))`
+	// anyDot is a dot, defanged or not
+	anyDot = `(?:\.|` + defDot + `)`
+)
+
+var (
+	// defangedScheme matches schemes like hxxp, hXXps or h**p
+	defangedScheme = regexp.MustCompile(`(?i)\bh(?:xx|\*\*|__)p(s?)(\[://\]|\[?:\]?//)`)
+	// defangedDot matches dots written like [.], (.), {.} or [dot]
+	defangedDot = regexp.MustCompile(`(?i)` + defDot)
+	// defangedColon matches ports and schemes written like [:]
+	defangedColon = regexp.MustCompile(`\[:\]`)
+	// defangedDomain matches domains and IPv4 addresses with at least one
+	// defanged dot
+	defangedDomain = regexp.MustCompile(`(?i)\b[a-z0-9-]+(?:` + anyDot + `[a-z0-9-]+)*` + defDot + `[a-z]{2,}\b|` +
+		`\b\d{1,3}(?:` + anyDot + `\d{1,3}){2}` + defDot + `\d{1,3}\b`)
+)
+
+// refang returns the text with the defanged URLs and domains restored, like
+// 'hxxps://evil[.]com' to 'https://evil.com'
+func refang(s string) string {
+	s = defangedScheme.ReplaceAllString(s, "http$1://")
+	s = defangedDot.ReplaceAllString(s, ".")
+	return defangedColon.ReplaceAllString(s, ":")
+}
+
+// newRefangFinder returns a finder of the defanged URLs, and of the
+// defanged domains without a scheme
+func (o *Options) newRefangFinder() func(string) []string {
+	findURL := o.newURLFinder()
+	return o.withPrefilter(mayBeDefanged, func(line string) []string {
+		if !defangedScheme.MatchString(line) && !defangedDot.MatchString(line) {
+			return nil
+		}
+
+		found := findURL(refang(line))
+		for _, loc := range defangedDomain.FindAllStringIndex(line, -1) {
+			// domains of defanged URLs and emails are already found, and
+			// the names in their paths and queries are not domains
+			before := line[:loc[0]]
+			if strings.HasSuffix(before, "//]") || before != "" && strings.ContainsRune("/@.?=&#", rune(before[len(before)-1])) {
+				continue
+			}
+			found = append(found, refang(line[loc[0]:loc[1]]))
+		}
+
+		return found
+	})
+}
+
+// defang returns the URL made safe to share, with the scheme as hxxp and
+// the dots of the host, or of the email domain, as [.]
+func defang(s string) string {
+	if addr, ok := strings.CutPrefix(s, "mailto:"); ok {
+		user, domain, found := strings.Cut(addr, "@")
+		if !found {
+			return s
+		}
+		return "mailto:" + user + "@" + strings.ReplaceAll(domain, ".", "[.]")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		if !strings.ContainsAny(s, "/:") {
+			// a bare domain
+			return strings.ReplaceAll(s, ".", "[.]")
+		}
+		return s
+	}
+
+	rest := strings.TrimPrefix(s, u.Scheme+"://")
+	i := strings.Index(rest, u.Host)
+	if i < 0 {
+		return s
+	}
+	rest = rest[:i] + strings.ReplaceAll(u.Host, ".", "[.]") + rest[i+len(u.Host):]
+
+	scheme := u.Scheme
+	if after, ok := strings.CutPrefix(scheme, "http"); ok {
+		scheme = "hxxp" + after
+	}
+
+	return scheme + "://" + rest
+}
+
+// outputValue returns the value written to STDOUT or copied, defanged with
+// --defang
+func (o *Options) outputValue(s string) string {
+	if o.Defang {
+		return defang(s)
+	}
+
+	return s
+}
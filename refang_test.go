@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRefang(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"hxxp://evil.example/a", "http://evil.example/a"},
+		{"hXXps://evil.example", "https://evil.example"},
+		{"h**ps://evil.example", "https://evil.example"},
+		{"h__p://evil.example", "http://evil.example"},
+		{"hxxp[:]//evil.example", "http://evil.example"},
+		{"hxxps[://]evil.example", "https://evil.example"},
+		{"https[:]//evil.example", "https://evil.example"},
+		{"evil[.]example", "evil.example"},
+		{"evil(.)example", "evil.example"},
+		{"evil{.}example", "evil.example"},
+		{"evil[dot]example", "evil.example"},
+		{"evil(DOT)example", "evil.example"},
+		{"hxxps://sub[.]evil(.)example[:]8443/x[.]php", "https://sub.evil.example:8443/x.php"},
+		{"10[.]0[.]0[.]1", "10.0.0.1"},
+		{"https://safe.example/a", "https://safe.example/a"},
+	}
+	for _, tt := range tests {
+		if got := refang(tt.in); got != tt.want {
+			t.Errorf("refang(%q): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDefang(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"http://evil.example/a.php", "hxxp://evil[.]example/a.php"},
+		{"https://sub.evil.example:8443/x?q=a.b", "hxxps://sub[.]evil[.]example:8443/x?q=a.b"},
+		{"ftp://files.example/f.txt", "ftp://files[.]example/f.txt"},
+		{"https://user@evil.example/", "hxxps://user@evil[.]example/"},
+		{"mailto:bob@mail.evil.example", "mailto:bob@mail[.]evil[.]example"},
+		{"evil.example", "evil[.]example"},
+		{"10.0.0.1", "10[.]0[.]0[.]1"},
+	}
+	for _, tt := range tests {
+		if got := defang(tt.in); got != tt.want {
+			t.Errorf("defang(%q): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDefangRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"http://evil.example/a.php",
+		"https://sub.evil.example:8443/x?q=a.b#c",
+		"https://user@evil.example/",
+		"mailto:bob@mail.evil.example",
+		"evil.example",
+		"192.168.1.10",
+	} {
+		if got := refang(defang(s)); got != s {
+			t.Errorf("refang(defang(%q)): got %q through %q", s, got, defang(s))
+		}
+	}
+}
+
+func TestRefangExtract(t *testing.T) {
+	input := "c2 at hxxps://evil[.]example/gate[.]php and hxxp[:]//10(.)0(.)0(.)1[:]8080/x, " +
+		"dropper on files{.}evil[.]example, not http://safe.example"
+	want := []string{
+		"http://safe.example",
+		"https://evil.example/gate.php",
+		"http://10.0.0.1:8080/x",
+		"files.evil.example",
+	}
+	if got := values(extractInput(t, testOptions(t, "--refang"), input)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := values(extractInput(t, testOptions(t), input)); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("without --refang: got %q, want %q", got, want[:1])
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/haaag/GoURL/extract"
+)
+
+// defaultSchemes are the schemes matched by urlRegex
+var defaultSchemes = extract.DefaultSchemes
+
+// schemeAllowed reports whether the scheme passes --scheme-allow and
+// --scheme-deny
+func (o *Options) schemeAllowed(scheme string) bool {
+	scheme = strings.ToLower(scheme)
+	if len(o.schemeAllow) > 0 && !inList(o.schemeAllow, scheme) {
+		return false
+	}
+
+	return !inList(o.schemeDeny, scheme)
+}
+
+// urlPattern returns the URL regex with the allowed schemes, 'www.' is
+// matched while http is
+func (o *Options) urlPattern() string {
+	if len(o.schemeAllow) == 0 && len(o.schemeDeny) == 0 {
+		return urlRegex
+	}
+
+	schemes := defaultSchemes
+	if len(o.schemeAllow) > 0 {
+		schemes = o.schemeAllow
+	}
+
+	var allowed []string
+	for _, s := range schemes {
+		if o.schemeAllowed(s) {
+			allowed = append(allowed, s)
+		}
+	}
+
+	return extract.URLPatternFor(allowed, o.schemeAllowed("http"))
+}
+
+// itemScheme returns the scheme of the item, http for 'www.' links
+func itemScheme(s string) string {
+	if strings.HasPrefix(strings.ToLower(s), "www.") {
+		return "http"
+	}
+
+	scheme, _, found := strings.Cut(s, ":")
+	if !found {
+		return ""
+	}
+
+	return strings.ToLower(scheme)
+}
+
+// wwwSchemes are the schemes that can be added to the 'www.' links
+var wwwSchemes = []string{"https", "http"}
+
+// validateDefaultScheme checks the value of --default-scheme
+func validateDefaultScheme(s string) error {
+	if !inList(wwwSchemes, s) {
+		return fmt.Errorf("%w: --default-scheme %q (valid: %s)", errInvalidFlag, s, strings.Join(wwwSchemes, ", "))
+	}
+
+	return nil
+}
+
+// withScheme returns the 'www.' link with the --default-scheme, so openers
+// get a full URL, other values are returned as they are
+func (o *Options) withScheme(s string) string {
+	if !strings.HasPrefix(strings.ToLower(s), "www.") {
+		return s
+	}
+
+	return o.DefaultScheme + "://" + s
+}
+
+// schemeItems returns a copy of the items with the scheme added to their
+// 'www.' links
+func (o *Options) schemeItems(items []Match) []Match {
+	result := make([]Match, len(items))
+	for i, m := range items {
+		m.Value = o.withScheme(m.Value)
+		result[i] = m
+	}
+
+	return result
+}
+
+// filterBySchemes returns the items whose scheme is allowed, items found
+// by other finders than the URL regex, like emails or custom regexes, are
+// filtered too
+func (o *Options) filterBySchemes(items []Match) []Match {
+	var result []Match
+	for _, m := range items {
+		if s := itemScheme(m.Value); s == "" || o.schemeAllowed(s) {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
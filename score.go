@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// weights of the confidence score, they add up to maxScore
+const (
+	scoreScheme   = 30
+	scoreWWW      = 20
+	scoreTLD      = 30
+	scoreOtherTLD = 10
+	scoreBalanced = 20
+	scoreLength   = 20
+	maxScore      = 100
+)
+
+// length limits of a sane URL and its host
+const (
+	minURLLength   = 8
+	maxURLLength   = 2048
+	maxHostLength  = 253
+	maxLabelLength = 63
+)
+
+// genericTLDs are the common generic top-level domains, two letters ones
+// are taken as country codes
+var genericTLDs = map[string]bool{
+	"com": true, "org": true, "net": true, "edu": true, "gov": true, "mil": true, "int": true,
+	"info": true, "biz": true, "name": true, "pro": true, "dev": true, "app": true, "io": true,
+	"xyz": true, "site": true, "online": true, "tech": true, "blog": true, "page": true,
+	"cloud": true, "wiki": true, "news": true, "shop": true, "store": true, "social": true,
+	"zone": true, "space": true, "email": true, "club": true, "live": true, "media": true,
+	"network": true, "systems": true, "software": true, "codes": true, "arpa": true,
+}
+
+// knownTLD reports whether the host ends in a known top-level domain, an
+// internationalized one, or is an IP address or localhost
+func knownTLD(host string) bool {
+	if host == "localhost" || net.ParseIP(host) != nil {
+		return true
+	}
+
+	i := strings.LastIndex(host, ".")
+	if i < 0 {
+		return false
+	}
+	tld := host[i+1:]
+	if genericTLDs[tld] || strings.HasPrefix(tld, "xn--") || !isASCII(tld) {
+		return true
+	}
+
+	return len(tld) == 2 && strings.Trim(tld, "abcdefghijklmnopqrstuvwxyz") == ""
+}
+
+// balanced reports whether the brackets of the value are balanced and it
+// does not end in punctuation, usually left from the surrounding text
+func balanced(s string) bool {
+	if strings.ContainsAny(s[len(s)-1:], `.,;:!?'"`) {
+		return false
+	}
+
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	var open []rune
+	for _, r := range s {
+		switch r {
+		case '(', '[', '{':
+			open = append(open, r)
+		case ')', ']', '}':
+			if len(open) == 0 || open[len(open)-1] != pairs[r] {
+				return false
+			}
+			open = open[:len(open)-1]
+		}
+	}
+
+	return len(open) == 0
+}
+
+// saneLength reports whether the value and its host have reasonable lengths
+func saneLength(s, host string) bool {
+	if len(s) < minURLLength || len(s) > maxURLLength || len(host) > maxHostLength {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > maxLabelLength {
+			return false
+		}
+	}
+
+	return true
+}
+
+// confidence returns how likely it is, from 0 to 100, that the value is a
+// real URL or email and not a false positive
+func confidence(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	var score int
+	var host string
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(lower, "mailto:"):
+		score += scoreScheme
+		host = emailDomain(lower)
+	case strings.HasPrefix(lower, "www."):
+		score += scoreWWW
+	case strings.Contains(lower, "://"):
+		score += scoreScheme
+	}
+
+	if host == "" {
+		if u, err := parseURL(s); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+	}
+
+	switch {
+	case knownTLD(host):
+		score += scoreTLD
+	case strings.Contains(host, "."):
+		score += scoreOtherTLD
+	}
+
+	if balanced(s) {
+		score += scoreBalanced
+	}
+
+	if host != "" && saneLength(s, host) {
+		score += scoreLength
+	}
+
+	return min(score, maxScore)
+}
+
+// scoreItems sets the confidence score of the items
+func scoreItems(items []Match) {
+	for i := range items {
+		items[i].Score = confidence(items[i].Value)
+	}
+}
+
+// filterByScore returns the items with a score of at least n
+func filterByScore(items []Match, n int) []Match {
+	var result []Match
+	for _, m := range items {
+		if m.Score >= n {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
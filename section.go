@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineRange is an inclusive range of line numbers, zero ends are open
+type lineRange struct {
+	start, end int
+}
+
+// parseLineRange parses the value of --lines, like 100:500, 100: or :500
+func parseLineRange(s string) (lineRange, error) {
+	var r lineRange
+	if s == "" {
+		return r, nil
+	}
+
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return r, fmt.Errorf("%w: --lines must be start:end, e.g. 100:500", errInvalidFlag)
+	}
+
+	var err error
+	if from != "" {
+		if r.start, err = strconv.Atoi(from); err != nil || r.start < 1 {
+			return r, fmt.Errorf("%w: --lines start must be a line number", errInvalidFlag)
+		}
+	}
+	if to != "" {
+		if r.end, err = strconv.Atoi(to); err != nil || r.end < 1 {
+			return r, fmt.Errorf("%w: --lines end must be a line number", errInvalidFlag)
+		}
+	}
+	if r.end > 0 && r.end < r.start {
+		return r, fmt.Errorf("%w: --lines end must not be before start", errInvalidFlag)
+	}
+
+	return r, nil
+}
+
+// compileGate compiles the regex of --after-match or --before-match
+func compileGate(name, s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: --%s: %w", errInvalidFlag, name, err)
+	}
+
+	return re, nil
+}
+
+// section tracks the part of a source scanned with --lines, --after-match,
+// --before-match and --head
+type section struct {
+	o    *Options
+	open bool
+	// done is set once no more lines of the source can be kept
+	done bool
+	// kept is the number of lines kept, last the number of the last one
+	kept, last int
+}
+
+func (o *Options) newSection() *section {
+	return &section{o: o, open: o.after == nil}
+}
+
+// keep reports whether the line is scanned. The lines matching the gates
+// are not, the section starts after --after-match and ends before
+// --before-match.
+func (s *section) keep(num int, text []byte) bool {
+	if s.done {
+		return false
+	}
+	if s.o.lines.end > 0 && num > s.o.lines.end {
+		s.done = true
+		return false
+	}
+	if num < s.o.lines.start {
+		return false
+	}
+
+	if !s.open {
+		s.open = s.o.after.Match(text)
+		return false
+	}
+	if s.o.before != nil && s.o.before.Match(text) {
+		s.done = true
+		return false
+	}
+
+	// the chunks of a long line are part of the same line
+	if num != s.last {
+		if s.o.Head > 0 && s.kept == s.o.Head {
+			s.done = true
+			return false
+		}
+		s.kept++
+		s.last = num
+	}
+
+	return true
+}
+
+// lineRing keeps the last lines pushed, for --tail
+type lineRing struct {
+	lines []inputLine
+	size  int
+	next  int
+}
+
+func newLineRing(size int) *lineRing {
+	return &lineRing{size: size}
+}
+
+func (r *lineRing) push(l inputLine) {
+	if len(r.lines) < r.size {
+		r.lines = append(r.lines, l)
+		return
+	}
+
+	r.lines[r.next] = l
+	r.next = (r.next + 1) % r.size
+}
+
+// ordered returns the lines kept from the oldest to the newest
+func (r *lineRing) ordered() []inputLine {
+	lines := make([]inputLine, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	return append(lines, r.lines[:r.next]...)
+}
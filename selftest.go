@@ -0,0 +1,197 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// selftestDir is the directory of the embedded selftest corpus, each case
+// is an input file with the expected values in <case>.golden and optional
+// flags in <case>.args
+const selftestDir = "selftest"
+
+//go:embed selftest
+var selftestFS embed.FS
+
+var errSelftest = errors.New("selftest failed")
+
+// selftestCase is an input of the selftest corpus
+type selftestCase struct {
+	name  string
+	input string
+	args  []string
+	want  []string
+}
+
+// selftestCases returns the cases of the corpus, sorted by name
+func selftestCases() ([]selftestCase, error) {
+	entries, err := selftestFS.ReadDir(selftestDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []selftestCase
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if ext == ".golden" || ext == ".args" {
+			continue
+		}
+		c := selftestCase{name: strings.TrimSuffix(e.Name(), ext), input: path.Join(selftestDir, e.Name())}
+		golden, err := selftestFS.ReadFile(path.Join(selftestDir, c.name+".golden"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s has no golden file", errSelftest, e.Name())
+		}
+		c.want = strings.Fields(string(golden))
+		if args, err := selftestFS.ReadFile(path.Join(selftestDir, c.name+".args")); err == nil {
+			if c.args, err = splitArgs(strings.TrimSpace(string(args))); err != nil {
+				return nil, fmt.Errorf("%w: %s.args: %w", errSelftest, c.name, err)
+			}
+		}
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].name < cases[j].name })
+
+	return cases, nil
+}
+
+// run extracts the values of the case input with the default options and
+// the flags of the case, the config and the command line are not used
+func (c *selftestCase) run() ([]string, error) {
+	o := newOptions()
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	o.registerFlags(fs)
+	if err := fs.Parse(c.args); err != nil {
+		return nil, err
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	f, err := selftestFS.Open(c.input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := o.convertInput(f)
+	if err != nil {
+		return nil, err
+	}
+
+	ex, err := o.extract([]source{{r: r, name: c.name}}, nil)
+	if errors.Is(err, errNoURLFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(ex.items))
+	for i := range ex.items {
+		values = append(values, ex.items[i].Value)
+	}
+
+	return values, nil
+}
+
+// lineDiff returns the values of want missing from got, and the ones of
+// got not in want
+func lineDiff(got, want []string) (missing, unexpected []string) {
+	inGot := make(map[string]bool, len(got))
+	for _, v := range got {
+		inGot[v] = true
+	}
+	inWant := make(map[string]bool, len(want))
+	for _, v := range want {
+		inWant[v] = true
+		if !inGot[v] {
+			missing = append(missing, v)
+		}
+	}
+	for _, v := range got {
+		if !inWant[v] {
+			unexpected = append(unexpected, v)
+		}
+	}
+
+	return missing, unexpected
+}
+
+// runSelftest runs the selftest subcommand, extracting the URLs of the
+// embedded corpus and comparing them with the expected ones, to check a
+// build or a change of the finders
+func runSelftest(_ *Options, args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "also list the cases that pass")
+	write := fs.String("write", "", "write the values found for each case to `dir`, to update the golden files")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s selftest [-v] [--write dir] [case ...]\n\nOptions:\n", appName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("selftest: %w", err)
+	}
+
+	cases, err := selftestCases()
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		var selected []selftestCase
+		for _, name := range fs.Args() {
+			i := sort.Search(len(cases), func(i int) bool { return cases[i].name >= name })
+			if i == len(cases) || cases[i].name != name {
+				return fmt.Errorf("%w: no case %q", errSelftest, name)
+			}
+			selected = append(selected, cases[i])
+		}
+		cases = selected
+	}
+
+	failed := 0
+	for i := range cases {
+		c := &cases[i]
+		got, err := c.run()
+		if err != nil {
+			fmt.Printf("FAIL %s\n  error: %s\n", c.name, err)
+			failed++
+			continue
+		}
+		if *write != "" {
+			out := strings.Join(got, "\n") + "\n"
+			if err := os.WriteFile(filepath.Join(*write, c.name+".golden"), []byte(out), 0o644); err != nil {
+				return fmt.Errorf("error writing golden file: %w", err)
+			}
+		}
+
+		missing, unexpected := lineDiff(got, c.want)
+		if len(missing) == 0 && len(unexpected) == 0 {
+			if *verbose {
+				fmt.Printf("PASS %s (%d values)\n", c.name, len(got))
+			}
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", c.name)
+		for _, v := range missing {
+			fmt.Printf("  missing:    %s\n", v)
+		}
+		for _, v := range unexpected {
+			fmt.Printf("  unexpected: %s\n", v)
+		}
+	}
+	fmt.Printf("selftest: %d/%d cases pass\n", len(cases)-failed, len(cases))
+
+	if failed > 0 {
+		return errSelftest
+	}
+
+	return nil
+}
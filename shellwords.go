@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var errUnterminated = errors.New("unterminated quote")
+
+// splitArgs splits the command line in words like a POSIX shell does:
+// words are separated by blanks, single quotes keep the text as it is,
+// double quotes keep blanks and take backslash escapes of \ " $ and `, and
+// a backslash outside quotes escapes the next character. No expansions are
+// done.
+func splitArgs(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		// inWord is set when a word was started, so quoted empty strings
+		// are words too
+		inWord bool
+		quote  rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(c)
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(runes) && strings.ContainsRune("\\\"$`\n", runes[i+1]):
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			default:
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("%w: %c in %q", errUnterminated, quote, s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// quoteArgs returns the words as a command line splitArgs splits back,
+// quoting the ones with blanks or special characters
+func quoteArgs(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./:=,+@%^", r)
+		}) < 0 {
+			quoted = append(quoted, arg)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// commandArgs returns the words of the command line, checked when the
+// flags and settings are set
+func commandArgs(s string) []string {
+	args, err := splitArgs(s)
+	if err != nil {
+		return strings.Fields(s)
+	}
+
+	return args
+}
+
+// checkCommand checks the quotes of the command line of the setting
+func checkCommand(name, s string) error {
+	if _, err := splitArgs(s); err != nil {
+		return fmt.Errorf("%w: %s: %w", errSetting, name, err)
+	}
+
+	return nil
+}
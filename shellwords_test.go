@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+		err  error
+	}{
+		{name: "blanks", in: " dmenu  -l\t10\n-i ", want: []string{"dmenu", "-l", "10", "-i"}},
+		{name: "empty", in: "", want: nil},
+		{name: "only blanks", in: " \t\n", want: nil},
+		{name: "single quotes", in: `echo 'a  b' 'c\d' 'e"f'`, want: []string{"echo", "a  b", `c\d`, `e"f`}},
+		{name: "double quotes", in: `echo "a  b" "it's"`, want: []string{"echo", "a  b", "it's"}},
+		{name: "double quote escapes", in: `"\\ \" \$ \` + "`" + ` \n"`, want: []string{`\ " $ ` + "` " + `\n`}},
+		{name: "escaped newline in quotes", in: "\"a\\\nb\"", want: []string{"ab"}},
+		{name: "backslash", in: `a\ b c\'d \"`, want: []string{"a b", "c'd", `"`}},
+		{name: "escaped newline", in: "a\\\nb", want: []string{"ab"}},
+		{name: "trailing backslash", in: `a\`, want: []string{"a"}},
+		{name: "joined quotes", in: `a'b'"c"d`, want: []string{"abcd"}},
+		{name: "empty args", in: `cmd '' "" x`, want: []string{"cmd", "", "", "x"}},
+		{name: "unicode", in: "echo 'héllo wörld' ñ", want: []string{"echo", "héllo wörld", "ñ"}},
+		{name: "unterminated single quote", in: "echo 'abc", err: errUnterminated},
+		{name: "unterminated double quote", in: `echo "abc\"`, err: errUnterminated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitArgs(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("splitArgs(%q): got error %v, want %v", tt.in, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitArgs(%q): got %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"dmenu", "-l", "10"}, want: "dmenu -l 10"},
+		{args: []string{"https://a.example/x?q=1&r=2"}, want: "'https://a.example/x?q=1&r=2'"},
+		{args: []string{"a b", ""}, want: "'a b' ''"},
+		{args: []string{"it's"}, want: `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := quoteArgs(tt.args); got != tt.want {
+			t.Errorf("quoteArgs(%q): got %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteArgsRoundTrip(t *testing.T) {
+	cases := [][]string{
+		{"rofi", "-dmenu", "-p", "URL>"},
+		{"a b", "", "'", `"`, `\`, "$HOME", "`x`", "*", "a\tb\nc"},
+		{"it's \"quoted\"", `back\slash`, "héllo wörld", "é"},
+	}
+	for _, args := range cases {
+		line := quoteArgs(args)
+		got, err := splitArgs(line)
+		if err != nil {
+			t.Fatalf("splitArgs(%q): %s", line, err)
+		}
+		if !reflect.DeepEqual(got, args) {
+			t.Errorf("round trip of %q through %s: got %q", args, line, got)
+		}
+	}
+}
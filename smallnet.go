@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// smallnetClient is a client for the gemini and gopher protocols
+type smallnetClient struct {
+	command string
+	// terminal is set for clients that run in a terminal
+	terminal bool
+}
+
+// smallnetClients are the clients tried for each scheme, in order, when
+// there is no opener for it in the config. xdg-open has usually no handler
+// for them.
+var smallnetClients = map[string][]smallnetClient{
+	"gemini": {
+		{command: "lagrange"},
+		{command: "kristall"},
+		{command: "amfora", terminal: true},
+		{command: "bombadillo", terminal: true},
+	},
+	"gopher": {
+		{command: "lagrange"},
+		{command: "kristall"},
+		{command: "lynx", terminal: true},
+		{command: "bombadillo", terminal: true},
+	},
+}
+
+// smallnetCmd returns the command of the first client found for the scheme
+// of the URL, nil if it is not gemini or gopher or there is no client.
+// Terminal clients run in $TERMINAL, or else in the current terminal and
+// wait is set, since the client needs it until it exits.
+func smallnetCmd(url string) (cmd *exec.Cmd, wait bool) {
+	scheme, _, _ := strings.Cut(url, "://")
+	for _, c := range smallnetClients[strings.ToLower(scheme)] {
+		if _, err := exec.LookPath(c.command); err != nil {
+			continue
+		}
+
+		if !c.terminal {
+			return exec.Command(c.command, url), false
+		}
+
+		if term := os.Getenv("TERMINAL"); term != "" {
+			return exec.Command(term, "-e", c.command, url), false
+		}
+
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(c.command, url)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+		return cmd, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	actionSnapshot = "snapshot"
+
+	snapshotTimeout  = 30 * time.Second
+	snapshotWorkers  = 8
+	snapshotManifest = "index.json"
+)
+
+// SnapshotEntry describes a saved response in the manifest
+type SnapshotEntry struct {
+	Time        time.Time `json:"time"`
+	URL         string    `json:"url"`
+	FinalURL    string    `json:"final_url,omitempty"`
+	File        string    `json:"file,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+}
+
+// snapshotDir returns the directory of the snapshots, --snapshot or the
+// cache dir
+func snapshotDir() (string, error) {
+	if snapshotFlag != "" {
+		if err := os.MkdirAll(snapshotFlag, 0o755); err != nil {
+			return "", fmt.Errorf("error creating snapshot dir: %w", err)
+		}
+		return snapshotFlag, nil
+	}
+
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, "snapshots")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating snapshot dir: %w", err)
+	}
+
+	return dir, nil
+}
+
+// snapshotExts are the preferred extensions of common content types, the
+// mime package lists several for them
+var snapshotExts = map[string]string{
+	"text/html":  ".html",
+	"text/plain": ".txt",
+	"image/jpeg": ".jpg",
+}
+
+// snapshotName returns the file name of the URL snapshot, its hash with an
+// extension from the content type or the URL path
+func snapshotName(rawURL, contentType string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(sum[:8])
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext, ok := snapshotExts[mediaType]; ok {
+			return name + ext
+		}
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			sort.Strings(exts)
+			return name + exts[0]
+		}
+	}
+
+	if u, err := parseURL(rawURL); err == nil {
+		if ext := path.Ext(u.Path); len(ext) > 1 && len(ext) <= 6 {
+			return name + ext
+		}
+	}
+
+	return name
+}
+
+// fetchSnapshot saves the body of the URL in dir
+func fetchSnapshot(client *http.Client, dir, rawURL string) SnapshotEntry {
+	entry := SnapshotEntry{Time: time.Now(), URL: rawURL}
+	fail := func(err error) SnapshotEntry {
+		entry.Error = err.Error()
+		log.Printf("%s: %s", rawURL, err)
+		return entry
+	}
+
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return fail(fmt.Errorf("error creating request: %w", err))
+	}
+	req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	entry.Status = resp.StatusCode
+	entry.FinalURL = resp.Request.URL.String()
+	entry.ContentType = resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK {
+		return fail(fmt.Errorf("%w: %s", errHTTPStatus, resp.Status))
+	}
+
+	entry.File = snapshotName(rawURL, entry.ContentType)
+	f, err := os.Create(filepath.Join(dir, entry.File))
+	if err != nil {
+		return fail(fmt.Errorf("error saving snapshot: %w", err))
+	}
+	defer f.Close()
+
+	if entry.Size, err = io.Copy(f, resp.Body); err != nil {
+		return fail(fmt.Errorf("error saving snapshot: %w", err))
+	}
+
+	return entry
+}
+
+// readManifest returns the entries of the manifest in dir
+func readManifest(dir string) ([]SnapshotEntry, error) {
+	b, err := os.ReadFile(filepath.Join(dir, snapshotManifest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	var entries []SnapshotEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+
+	return entries, nil
+}
+
+// writeManifest merges the entries into the manifest in dir, replacing the
+// previous snapshots of the same URLs
+func writeManifest(dir string, entries []SnapshotEntry) error {
+	old, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(old))
+	for i, e := range old {
+		index[e.URL] = i
+	}
+	for _, e := range entries {
+		if i, ok := index[e.URL]; ok {
+			old[i] = e
+			continue
+		}
+		index[e.URL] = len(old)
+		old = append(old, e)
+	}
+
+	b, err := json.MarshalIndent(old, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, snapshotManifest), b, 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotItems saves the responses of the web items and updates the
+// manifest, returning the number of URLs saved
+func snapshotItems(items []Match) (int, error) {
+	dir, err := snapshotDir()
+	if err != nil {
+		return 0, err
+	}
+
+	client := newHTTPClient(snapshotTimeout)
+	entries := make([]SnapshotEntry, len(items))
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < snapshotWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ch {
+				entries[i] = fetchSnapshot(client, dir, items[i].Value)
+			}
+		}()
+	}
+
+	for i := range items {
+		if checkable(&items[i]) {
+			ch <- i
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	var saved []SnapshotEntry
+	var n int
+	for _, e := range entries {
+		if e.URL == "" {
+			continue
+		}
+		saved = append(saved, e)
+		if e.File != "" {
+			n++
+		}
+	}
+
+	return n, writeManifest(dir, saved)
+}
+
+// snapshotAction saves the selected URLs to the snapshot dir
+type snapshotAction struct{}
+
+func (snapshotAction) Name() string { return actionSnapshot }
+
+func (snapshotAction) Run(_ context.Context, _ *Options, items []Match) error {
+	n, err := snapshotItems(items)
+	if err != nil {
+		return err
+	}
+
+	printInfo(fmt.Sprintf("%d of %d URL(s) saved", n, len(items)))
+	return nil
+}
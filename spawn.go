@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stderrTailSize is the number of bytes kept of what a command prints to
+// stderr, for its errors
+const stderrTailSize = 4096
+
+// stderrDelay is how long the stderr of a command that exited is read
+const stderrDelay = 100 * time.Millisecond
+
+// actionGrace is how long gourl waits on exit for the commands started in
+// the background, the openers that fail, like xdg-open with no application
+// for the URL, do so at once
+const actionGrace = 500 * time.Millisecond
+
+var errActionTimeout = errors.New("timed out")
+
+var (
+	// background are the commands started in the background not reaped
+	// yet, backgroundFailed is set when one of them fails
+	background       sync.WaitGroup
+	backgroundFailed atomic.Bool
+)
+
+// tailBuffer keeps the last bytes written to it, so commands printing for
+// hours, like browsers, don't grow it
+type tailBuffer struct {
+	b []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > stderrTailSize {
+		t.b = append([]byte(nil), t.b[len(t.b)-stderrTailSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// lastLines returns the last n lines of the text, joined with "; "
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+
+	return strings.Join(lines[max(0, len(lines)-n):], "; ")
+}
+
+// startCmd starts the command and reaps it in the background when it
+// exits, reporting its exit status when it fails, its stderr goes to
+// /dev/null so it doesn't depend on gourl to keep running. With
+// --action-timeout it waits for the command to finish, killing it when it
+// takes longer, and its errors include what it printed.
+func startCmd(cmd *exec.Cmd) error {
+	if actTimeoutFlag == 0 {
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			if err := cmd.Wait(); err != nil {
+				backgroundFailed.Store(true)
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", appName, cmd.Args[0], err)
+			}
+		}()
+		return nil
+	}
+
+	stderr := &tailBuffer{}
+	if cmd.Stderr == nil {
+		cmd.Stderr = stderr
+	}
+	// the programs started by the command, like the browser started by
+	// xdg-open, keep its stderr open
+	cmd.WaitDelay = stderrDelay
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, exec.ErrWaitDelay) {
+			return nil
+		}
+		if err != nil && len(stderr.b) > 0 {
+			return fmt.Errorf("%w: %s", err, lastLines(string(stderr.b), menuErrLines))
+		}
+		return err
+	case <-time.After(actTimeoutFlag):
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		log.Printf("error killing %s: %s", cmd.Args[0], err)
+	}
+	<-done
+
+	return fmt.Errorf("%s: %w after %s, killed", cmd.Args[0], errActionTimeout, actTimeoutFlag)
+}
+
+// waitBackground waits up to d for the commands started in the background
+// to exit, so the ones failing at once are reported before gourl exits, and
+// reports whether any of them failed
+func waitBackground(d time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		log.Printf("leaving the actions running in the background")
+	}
+
+	return backgroundFailed.Load()
+}
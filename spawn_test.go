@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestStartCmdBackground(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+	t.Cleanup(func() { backgroundFailed.Store(false) })
+
+	if err := startCmd(exec.Command("true")); err != nil {
+		t.Fatal(err)
+	}
+	if waitBackground(time.Second) {
+		t.Error("true reported as failed")
+	}
+
+	if err := startCmd(exec.Command("false")); err != nil {
+		t.Fatal(err)
+	}
+	if !waitBackground(time.Second) {
+		t.Error("false not reported as failed")
+	}
+
+	// the commands still running are left alone
+	cmd := exec.Command("sleep", "5")
+	if err := startCmd(cmd); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		background.Wait()
+	})
+	start := time.Now()
+	waitBackground(50 * time.Millisecond)
+	if time.Since(start) > time.Second {
+		t.Error("waited for the command still running")
+	}
+}
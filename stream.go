@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sync"
+)
+
+// streamBuffer is the number of menu lines found ahead of the menu
+const streamBuffer = 256
+
+// itemStream sends the items to the menu as the extraction finds them,
+// keeping the text shown for each to map the selection back to its item
+type itemStream struct {
+	opts  *Options
+	mu    sync.Mutex
+	seen  map[string]bool
+	shown map[string]Match
+	lines chan string
+}
+
+func newItemStream(o *Options) *itemStream {
+	return &itemStream{
+		opts:  o,
+		seen:  make(map[string]bool),
+		shown: make(map[string]Match),
+		lines: make(chan string, streamBuffer),
+	}
+}
+
+// add sends the new items kept by the filters to the menu
+func (s *itemStream) add(items []Match) {
+	for _, m := range items {
+		m, ok := s.opts.streamItem(m)
+		if !ok {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.seen[m.Value] {
+			s.mu.Unlock()
+			continue
+		}
+		s.seen[m.Value] = true
+		line := s.opts.formatItem(&m, len(s.seen)-1, true)
+		s.shown[ansiEscape.ReplaceAllString(line, "")] = m
+		s.mu.Unlock()
+
+		s.lines <- line
+	}
+}
+
+// selected returns the items of the menu output
+func (s *itemStream) selected(output string) ([]Match, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return selectedItems(output, s.shown)
+}
+
+// streamsToMenu reports whether the items are sent to the menu while the
+// input is scanned: the menu shows its input as it reads it, the items are
+// picked in it, and nothing needs all of them first
+func streamsToMenu(o *Options) bool {
+	selects := selectedAction() != "" || menuArgsFlag != ""
+	icons := menu.Icon != nil && (inList(menu.Arguments, "-show-icons") || inList(commandArgs(menuArgsFlag), "-show-icons"))
+
+	return menu.Streams && o.streams() && selects && !selJSONFlag &&
+		!firstFlag && !lastFlag && selectFlag == 0 && pageSizeFlag <= 0 && !icons &&
+		!forgeFlag && !mediaInfoFlag && !decodeFlag && !checkFlag && !interactiveFlag &&
+		metricsFlag == "" && snapshotFlag == ""
+}
+
+// extractResult is the end of an extraction run in the background
+type extractResult struct {
+	ex  *extraction
+	err error
+}
+
+// handleStream shows the items in the menu as they are found, so it shows
+// up before a large input is scanned. The menu starts with the second item,
+// a single one is selected without it like when not streaming.
+func handleStream(o *Options, sources []source) {
+	s := newItemStream(o)
+	done := make(chan extractResult, 1)
+	go func() {
+		ex, err := o.extract(sources, s.add)
+		close(s.lines)
+		done <- extractResult{ex, err}
+	}()
+
+	need := 1
+	if selectedAction() != "" && !noAutoSelFlag {
+		need = 2
+	}
+	var first []string
+	for len(first) < need {
+		line, ok := <-s.lines
+		if !ok {
+			break
+		}
+		first = append(first, line)
+	}
+	if len(first) < need {
+		// the scan ended before the menu was needed
+		r := <-done
+		logErrAndExit(r.err)
+		handleItems(o, r.ex.items)
+		return
+	}
+
+	menu.addArgs()
+	menu.handlePrompt()
+	if wantsMulti(selectedAction()) {
+		menu.Arguments = append(menu.Arguments, menu.MultiArgs...)
+	}
+
+	output, err := menu.showStream(first, s.lines)
+	exitOnMenuError(err)
+	selected, ok := s.selected(output)
+	if !ok {
+		return
+	}
+
+	handleSelection(o, nil, selected)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFeedMenuDrains(t *testing.T) {
+	r, w := io.Pipe()
+	rest := make(chan string)
+	go feedMenu(w, []string{"first"}, rest)
+
+	// the menu reads a line and exits
+	buf := make([]byte, len("first\n"))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < streamBuffer*2; i++ {
+			rest <- "line"
+		}
+		close(rest)
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lines sent after the menu exited are not read")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// wideRanges are the ranges of the runes shown two columns wide: CJK,
+// Hangul, fullwidth forms and emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f}, {0x2e80, 0x303e}, {0x3041, 0x33ff}, {0x3400, 0x4dbf},
+	{0x4e00, 0x9fff}, {0xa000, 0xa4cf}, {0xac00, 0xd7a3}, {0xf900, 0xfaff},
+	{0xfe30, 0xfe4f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x1f300, 0x1f64f},
+	{0x1f900, 0x1f9ff}, {0x20000, 0x3fffd},
+}
+
+// runeWidth returns the number of columns the rune takes in a terminal
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, w := range wideRanges {
+		if r >= w[0] && r <= w[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// textWidth returns the number of columns the text takes in a terminal
+func textWidth(s string) int {
+	var n int
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+
+	return n
+}
+
+// markSpans returns a line with carets under the spans of the line, tabs
+// are kept and the columns counted so the carets line up
+func markSpans(line string, spans [][]int) string {
+	var b strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s[0] < pos {
+			continue
+		}
+		for _, r := range line[pos:s[0]] {
+			if r == '\t' {
+				b.WriteRune('\t')
+			} else {
+				b.WriteString(strings.Repeat(" ", runeWidth(r)))
+			}
+		}
+		b.WriteString(strings.Repeat("^", max(textWidth(line[s[0]:s[1]]), 1)))
+		pos = s[1]
+	}
+
+	return b.String()
+}
+
+// testMatch is an item found by a finder in a line, with its span in the
+// line, nil when the finder rewrote it, like the deobfuscated emails
+type testMatch struct {
+	value string
+	span  []int
+}
+
+// locate returns the matches of the items found in the line, the span of
+// each is searched after the one before, with the text added by the
+// finders removed
+func (o *Options) locate(line string, found []string) []testMatch {
+	matches := make([]testMatch, 0, len(found))
+	pos := 0
+	for _, v := range found {
+		m := testMatch{value: decodeEntities(v)}
+		text := strings.TrimSuffix(strings.TrimPrefix(v, o.Prefix), o.Suffix)
+		for _, s := range []string{text, strings.TrimPrefix(text, "mailto:")} {
+			if i := strings.Index(line[pos:], s); s != "" && i >= 0 {
+				m.span = []int{pos + i, pos + i + len(s)}
+				pos = m.span[1]
+				break
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	return matches
+}
+
+// explainLine writes the items found by each finder in the line, with
+// their spans, and with the custom regex the groups matched
+func (o *Options) explainLine(w io.Writer, num int, line string, finders []finder, re *regexp.Regexp) {
+	fmt.Fprintf(w, "%d: %s\n", num, line)
+
+	var found bool
+	for _, f := range finders {
+		matches := o.locate(line, f.find(line))
+		if len(matches) == 0 {
+			continue
+		}
+		found = true
+
+		var spans [][]int
+		for _, m := range matches {
+			if m.span != nil {
+				spans = append(spans, m.span)
+			}
+		}
+		if len(spans) > 0 {
+			fmt.Fprintf(w, "%*s  %s\n", len(fmt.Sprint(num)), "", markSpans(line, spans))
+		}
+
+		for _, m := range matches {
+			if m.span == nil {
+				fmt.Fprintf(w, "  %s (rewritten) %q\n", f.name, m.value)
+				continue
+			}
+			fmt.Fprintf(w, "  %s [%d:%d] %q\n", f.name, m.span[0], m.span[1], m.value)
+			if re != nil {
+				explainGroups(w, line, re, m.span[0])
+			}
+		}
+	}
+
+	if !found {
+		fmt.Fprintln(w, "  no match")
+	}
+}
+
+// explainGroups writes the groups of the regex match starting at start
+func explainGroups(w io.Writer, line string, re *regexp.Regexp, start int) {
+	for _, s := range re.FindAllStringSubmatchIndex(line, -1) {
+		if s[0] != start {
+			continue
+		}
+		for g := 1; g < len(s)/2; g++ {
+			if s[2*g] < 0 {
+				continue
+			}
+			name := re.SubexpNames()[g]
+			if name == "" {
+				name = fmt.Sprint(g)
+			}
+			fmt.Fprintf(w, "    group %s [%d:%d] %q\n", name, s[2*g], s[2*g+1], line[s[2*g]:s[2*g+1]])
+		}
+		return
+	}
+}
+
+// testRegex writes what the finders of the options find in each line of
+// the input, with explain the spans and groups matched
+func (o *Options) testRegex(w io.Writer, r io.Reader, explain bool) error {
+	finders := o.namedFinders()
+	var re *regexp.Regexp
+	if o.Regex != "" {
+		re = regexp.MustCompile(o.customPattern(o.Regex))
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, o.MaxLine)
+	for num := 1; sc.Scan(); num++ {
+		line := sc.Text()
+		if explain {
+			o.explainLine(w, num, line, finders, re)
+			continue
+		}
+
+		for _, f := range finders {
+			for _, v := range f.find(line) {
+				fmt.Fprintf(w, "%d: %s %s\n", num, f.name, decodeEntities(v))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+
+	return nil
+}
+
+// runTestRegex runs the test-regex subcommand, showing what the finders, or
+// the regex given with -E, match in the sample lines
+func runTestRegex(o *Options, args []string) error {
+	t := *o
+	fs := flag.NewFlagSet("test-regex", flag.ExitOnError)
+	fs.StringVar(&t.Regex, "E", t.Regex, "regex to test instead of the built-in finders")
+	fs.StringVar(&t.Regex, "regex", t.Regex, "regex to test instead of the built-in finders")
+	fs.BoolVar(&t.Fixed, "F", t.Fixed, "match the regex as a literal string")
+	fs.BoolVar(&t.Fixed, "fixed-string", t.Fixed, "match the regex as a literal string")
+	fs.StringVar(&t.RegexFlags, "regex-flags", t.RegexFlags, "flags of the regex, like i,m,s")
+	explain := fs.Bool("explain", false, "show the spans and groups matched in each line")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s test-regex [-E pattern [-F] [--regex-flags flags]] [--explain] [file ...]\n\nOptions:\n", appName)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("test-regex: %w", err)
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	if t.Engine == enginePCRE {
+		return fmt.Errorf("%w: test-regex runs the re2 regexes, not --engine %s", errInvalidFlag, enginePCRE)
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		readers := make([]io.Reader, 0, fs.NArg())
+		for _, path := range fs.Args() {
+			f, err := readFile(path)
+			if err != nil {
+				return err
+			}
+			readers = append(readers, f)
+		}
+		r = io.MultiReader(readers...)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	return t.testRegex(w, r, *explain)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// uniqueSorted returns the distinct values, sorted
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func TestTestRegexMatchesExtraction(t *testing.T) {
+	lines := []string{
+		"Go to https://example.com. or ftp://user@host.example/ (https://a.example/x)",
+		"mail bob@example.com, or bob [at] example [dot] com",
+		"HTTPS://Example.com/A?x=1&amp;y=2 and www.example.org.",
+		"hxxps://evil[.]example/x and evil[.]example",
+		"fixes TICKET-12 and ticket-7, see https://example.com",
+		"日本 https://例え.jp/パス",
+		"nothing here",
+	}
+	args := [][]string{
+		nil,
+		{"--refang"},
+		{"-E", `ticket-\d+`, "--regex-flags", "i"},
+		{"-E", "a.example", "-F", "--prefix", "<", "--suffix", ">"},
+	}
+	for _, a := range args {
+		o := testOptions(t, a...)
+		for _, line := range lines {
+			var b bytes.Buffer
+			if err := o.testRegex(&b, strings.NewReader(line), false); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+				if _, value, ok := strings.Cut(strings.TrimPrefix(l, "1: "), " "); ok {
+					got = append(got, value)
+				}
+			}
+
+			want := values(extractInput(t, o, line))
+			if got, want := uniqueSorted(got), uniqueSorted(want); !reflect.DeepEqual(got, want) {
+				t.Errorf("flags %q, %q: test-regex found %q, the extraction %q", a, line, got, want)
+			}
+		}
+	}
+}
+
+func TestExplainLine(t *testing.T) {
+	o := testOptions(t)
+	var b bytes.Buffer
+	if err := o.testRegex(&b, strings.NewReader("日本 https://例え.jp and bob [at] example [dot] com"), true); err != nil {
+		t.Fatal(err)
+	}
+	want := "1: 日本 https://例え.jp and bob [at] example [dot] com\n" +
+		"        ^^^^^^^^^^^^^^^\n" +
+		"  url [7:24] \"https://例え.jp\"\n" +
+		"  email (rewritten) \"mailto:bob@example.com\"\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestMarkSpans(t *testing.T) {
+	tests := []struct {
+		line  string
+		spans [][]int
+		want  string
+	}{
+		{line: "ab cd", spans: [][]int{{3, 5}}, want: "   ^^"},
+		{line: "\tx", spans: [][]int{{1, 2}}, want: "\t^"},
+		{line: "é x", spans: [][]int{{3, 4}}, want: "  ^"},
+		{line: "日本 ab", spans: [][]int{{0, 6}, {7, 9}}, want: "^^^^ ^^"},
+		{line: "é x", spans: [][]int{{4, 5}}, want: "  ^"},
+	}
+	for _, tt := range tests {
+		if got := markSpans(tt.line, tt.spans); got != tt.want {
+			t.Errorf("markSpans(%q, %v) = %q, want %q", tt.line, tt.spans, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timelineWidth is the number of columns of the sparklines
+const timelineWidth = 30
+
+var errNoTimestamps = errors.New("no timestamps found in the input")
+
+// sparkTicks are the sparkline bars, from the lowest count to the highest
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// logTimestamp is a timestamp format of the log lines
+type logTimestamp struct {
+	re      *regexp.Regexp
+	layouts []string
+	// iso is set for ISO 8601, written with a space or a T and a comma or
+	// a dot before the fraction
+	iso bool
+}
+
+// logTimestamps are the timestamp formats found in logs: ISO 8601 and
+// RFC 3339, the common log format of web servers and syslog
+var logTimestamps = []logTimestamp{
+	{
+		re:      regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+		layouts: []string{"2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05"},
+		iso:     true,
+	},
+	{
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	{
+		re:      regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`),
+		layouts: []string{time.Stamp},
+	},
+}
+
+// lineTime returns the first timestamp of the log line
+func lineTime(line string) (time.Time, bool) {
+	for _, ts := range logTimestamps {
+		s := ts.re.FindString(line)
+		if s == "" {
+			continue
+		}
+		if ts.iso {
+			s = strings.Replace(strings.Replace(s, " ", "T", 1), ",", ".", 1)
+		}
+		for _, layout := range ts.layouts {
+			t, err := time.ParseInLocation(layout, s, time.Local)
+			if err != nil {
+				continue
+			}
+			// syslog timestamps have no year, they are from the last year
+			if t.Year() == 0 {
+				now := time.Now()
+				t = t.AddDate(now.Year(), 0, 0)
+				if t.After(now) {
+					t = t.AddDate(-1, 0, 0)
+				}
+			}
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// lineKey identifies an input line
+type lineKey struct {
+	source string
+	num    int
+}
+
+// timelineEntry is the occurrences of a URL over time
+type timelineEntry struct {
+	value       string
+	first, last time.Time
+	times       []time.Time
+}
+
+// sparkline returns the occurrences in the buckets from start, scaled to
+// the bucket with the most of them
+func sparkline(counts []int) string {
+	highest := 0
+	for _, c := range counts {
+		highest = max(highest, c)
+	}
+
+	var b strings.Builder
+	for _, c := range counts {
+		if c == 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(sparkTicks[(c*len(sparkTicks)-1)/highest])
+	}
+
+	return b.String()
+}
+
+// bucketCounts returns the occurrences in each of the n buckets of width
+// from start
+func bucketCounts(times []time.Time, start time.Time, width time.Duration, n int) []int {
+	counts := make([]int, n)
+	for _, t := range times {
+		counts[min(int(t.Sub(start)/width), n-1)]++
+	}
+
+	return counts
+}
+
+// runTimeline prints when the URLs of the log input were found, with a
+// sparkline of their occurrences over time. The lines without a timestamp
+// take the one of the line before them, like the continuation lines of
+// stack traces.
+func runTimeline(o *Options, sources []source) error {
+	data := o.processInputData(sources)
+	times := make(map[lineKey]time.Time, len(data))
+	var last time.Time
+	for _, line := range data {
+		if t, ok := lineTime(line.text); ok {
+			last = t
+		}
+		if !last.IsZero() {
+			times[lineKey{line.source, line.num}] = last
+		}
+	}
+	if len(times) == 0 {
+		return errNoTimestamps
+	}
+
+	var items []Match
+	for _, find := range o.finders() {
+		items = append(items, scanItems(data, find)...)
+	}
+
+	entries := make(map[string]*timelineEntry)
+	var start, end time.Time
+	for i := range items {
+		t, ok := times[lineKey{items[i].Source, items[i].Line}]
+		if !ok {
+			continue
+		}
+		e := entries[items[i].Value]
+		if e == nil {
+			e = &timelineEntry{value: items[i].Value, first: t, last: t}
+			entries[items[i].Value] = e
+		}
+		e.times = append(e.times, t)
+		if t.Before(e.first) {
+			e.first = t
+		}
+		if t.After(e.last) {
+			e.last = t
+		}
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+		if t.After(end) {
+			end = t
+		}
+	}
+	if len(entries) == 0 {
+		return errNoURLFound
+	}
+
+	sorted := make([]*timelineEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].first.Equal(sorted[j].first) {
+			return sorted[i].first.Before(sorted[j].first)
+		}
+		return sorted[i].value < sorted[j].value
+	})
+
+	width := max(time.Second, (end.Sub(start) / timelineWidth).Round(time.Second))
+
+	switch o.Format {
+	case formatCSV, formatTSV:
+		sep := ','
+		if o.Format == formatTSV {
+			sep = '\t'
+		}
+		header := []string{"url", "count", "first", "last"}
+		for i := 0; i < timelineWidth; i++ {
+			header = append(header, start.Add(time.Duration(i)*width).Format(time.RFC3339))
+		}
+		rows := make([][]string, 0, len(sorted))
+		for _, e := range sorted {
+			row := []string{o.outputValue(e.value), strconv.Itoa(len(e.times)), e.first.Format(time.RFC3339), e.last.Format(time.RFC3339)}
+			for _, c := range bucketCounts(e.times, start, width, timelineWidth) {
+				row = append(row, strconv.Itoa(c))
+			}
+			rows = append(rows, row)
+		}
+		return writeRows(header, rows, sep)
+	}
+
+	fmt.Printf("# %s - %s, %s per column\n", start.Format(time.DateTime), end.Format(time.DateTime), width)
+	for _, e := range sorted {
+		fmt.Printf("%s  %s  %5d  |%s|  %s\n", e.first.Format(time.DateTime), e.last.Format(time.DateTime),
+			len(e.times), sparkline(bucketCounts(e.times, start, width, timelineWidth)), o.outputValue(e.value))
+	}
+
+	return nil
+}
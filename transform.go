@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const expandTimeout = 10 * time.Second
+
+// trackingParams are the query parameters removed by --clean, the ones
+// ending in '_' are prefixes
+var trackingParams = []string{
+	"utm_", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "igshid", "_hsenc", "_hsmi", "mkt_tok", "ref_src", "oly_anon_id",
+	"oly_enc_id", "vero_id", "wickedid", "__s",
+}
+
+// isTrackingParam reports whether the query parameter is used for tracking
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range trackingParams {
+		if name == p || strings.HasSuffix(p, "_") && strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanURL removes the tracking parameters of the http URL, the others are
+// kept as they were written
+func cleanURL(s string) string {
+	base, query, ok := strings.Cut(s, "?")
+	lower := strings.ToLower(s)
+	if !ok || !strings.HasPrefix(lower, "http") && !strings.HasPrefix(lower, "www.") {
+		return s
+	}
+
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	var kept []string
+	for _, param := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if param != "" && !isTrackingParam(name) {
+			kept = append(kept, param)
+		}
+	}
+
+	if len(kept) > 0 {
+		base += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+
+	return base
+}
+
+// cleanItems removes the tracking parameters of the items
+func cleanItems(items []Match) {
+	for i := range items {
+		items[i].Value = cleanURL(items[i].Value)
+	}
+}
+
+// expandURL returns the URL the link redirects to, for short links
+func expandURL(client *http.Client, rawURL string) (string, error) {
+	u, err := parseURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return rawURL, nil
+	}
+
+	var resp *http.Response
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, u.String(), http.NoBody)
+		if err != nil {
+			return "", fmt.Errorf("error creating request: %w", err)
+		}
+		req.Header.Set("User-Agent", appName+"/"+appVersion)
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("error expanding %s: %w", rawURL, err)
+		}
+		resp.Body.Close()
+
+		// some servers do not allow HEAD
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			break
+		}
+	}
+
+	if resp.Request.URL.String() == u.String() {
+		return rawURL, nil
+	}
+
+	return resp.Request.URL.String(), nil
+}
+
+// resolveAll runs fn on each value concurrently and returns the results,
+// the values that fail are mapped to themselves
+func resolveAll(values []string, fn func(string) (string, error)) map[string]string {
+	var pending []string
+	resolved := make(map[string]string, len(values))
+	for _, v := range values {
+		if _, ok := resolved[v]; !ok {
+			resolved[v] = v
+			pending = append(pending, v)
+		}
+	}
+
+	var mu sync.Mutex
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < checkWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range ch {
+				r, err := fn(v)
+				if err != nil {
+					log.Print(err)
+					continue
+				}
+				mu.Lock()
+				resolved[v] = r
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, v := range pending {
+		ch <- v
+	}
+	close(ch)
+	wg.Wait()
+
+	return resolved
+}
+
+// itemValues returns the values of the items
+func itemValues(items []Match) []string {
+	values := make([]string, 0, len(items))
+	for i := range items {
+		values = append(values, items[i].Value)
+	}
+
+	return values
+}
+
+// expandItems replaces the links with the URL they redirect to, the
+// original is kept as the label. Links that fail are left as they are.
+func expandItems(items []Match) {
+	client := newHTTPClient(expandTimeout)
+	expanded := resolveAll(itemValues(items), func(s string) (string, error) {
+		return expandURL(client, s)
+	})
+
+	for i := range items {
+		v := expanded[items[i].Value]
+		if v == items[i].Value {
+			continue
+		}
+		if items[i].Label == "" {
+			items[i].Label = items[i].Value
+		}
+		items[i].Value = v
+	}
+}
+
+// rewriteItems applies the URL rewrites selected with the flags
+func (o *Options) rewriteItems(items []Match) {
+	if o.Unwrap {
+		unwrapItems(items)
+	}
+
+	if o.Clean {
+		cleanItems(items)
+	}
+
+	if o.Expand {
+		expandItems(items)
+	}
+
+	if o.Canonical {
+		canonicalizeItems(items)
+	}
+
+	// short links can point to redirectors
+	if o.Unwrap && o.Expand {
+		unwrapItems(items)
+	}
+
+	// the expanded and canonical URLs can have tracking parameters too
+	if o.Clean && (o.Expand || o.Canonical) {
+		cleanItems(items)
+	}
+
+	if o.Punycode || o.Encode {
+		normalizeIRIs(items, o.Punycode, o.Encode)
+	}
+}
+
+// runTransform applies the rewrites to the URLs of the input, one per line,
+// and prints them in the same order without extracting them from text
+func runTransform(o *Options, sources []source) error {
+	var items []Match
+	for _, line := range o.processInputData(sources) {
+		s := strings.TrimSpace(line.text)
+		if s == "" {
+			continue
+		}
+		if o.Refang {
+			s = refang(s)
+		}
+		items = append(items, Match{Value: decodeEntities(s), Source: line.source, Line: line.num})
+	}
+
+	if len(items) == 0 {
+		return errNoURLFound
+	}
+
+	o.rewriteItems(items)
+	if hooks.has(hookTransform) {
+		if err := hooks.transformHook(items); err != nil {
+			return err
+		}
+	}
+
+	for i := range items {
+		fmt.Println(o.outputValue(items[i].Value))
+	}
+
+	return nil
+}
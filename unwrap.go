@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// UnwrapRule is a redirector whose destination is in a query parameter.
+// Host and Path are glob patterns, an empty Path matches any path.
+type UnwrapRule struct {
+	Host  string `json:"host"`
+	Path  string `json:"path"`
+	Param string `json:"param"`
+}
+
+// builtinUnwrapRules are the common redirectors, the rules of the config
+// are tried before them
+var builtinUnwrapRules = []UnwrapRule{
+	{Host: "google.*", Path: "/url", Param: "q"},
+	{Host: "www.google.*", Path: "/url", Param: "q"},
+	{Host: "www.google.*", Path: "/url", Param: "url"},
+	{Host: "*.safelinks.protection.outlook.com", Param: "url"},
+	{Host: "l.facebook.com", Path: "/l.php", Param: "u"},
+	{Host: "lm.facebook.com", Path: "/l.php", Param: "u"},
+	{Host: "l.messenger.com", Path: "/l.php", Param: "u"},
+	{Host: "l.instagram.com", Param: "u"},
+	{Host: "www.youtube.com", Path: "/redirect", Param: "q"},
+	{Host: "out.reddit.com", Param: "url"},
+	{Host: "slack-redir.net", Path: "/link", Param: "url"},
+	{Host: "steamcommunity.com", Path: "/linkfilter/", Param: "url"},
+	{Host: "t.umblr.com", Path: "/redirect", Param: "z"},
+}
+
+// unwrapRules are the rules used by --unwrap
+var unwrapRules = builtinUnwrapRules
+
+// validate checks the rule has a host and a param, and valid patterns
+func (r *UnwrapRule) validate() error {
+	if r.Host == "" || r.Param == "" {
+		return fmt.Errorf("%w: unwrap rules need a host and a param", errConfig)
+	}
+	for _, p := range []string{r.Host, r.Path} {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: unwrap: %q: %w", errConfig, p, err)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the URL is a redirect of the rule
+func (r *UnwrapRule) matches(u *url.URL) bool {
+	if ok, _ := path.Match(strings.ToLower(r.Host), strings.ToLower(u.Hostname())); !ok {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Path, u.Path)
+
+	return ok
+}
+
+// registerUnwrapRules adds the unwrap rules of the config before the
+// built-in ones
+func registerUnwrapRules(c *Config) error {
+	for i := range c.Unwrap {
+		if err := c.Unwrap[i].validate(); err != nil {
+			return err
+		}
+	}
+	unwrapRules = append(c.Unwrap, builtinUnwrapRules...)
+
+	return nil
+}
+
+// unwrapURL returns the destination of the redirector URL, following the
+// redirectors wrapping other redirectors. Other URLs are returned as they
+// are.
+func unwrapURL(s string) string {
+	for depth := 0; depth < maxEmbedDepth; depth++ {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" || u.RawQuery == "" {
+			return s
+		}
+
+		dest := ""
+		for i := range unwrapRules {
+			if !unwrapRules[i].matches(u) {
+				continue
+			}
+			v := u.Query().Get(unwrapRules[i].Param)
+			lower := strings.ToLower(v)
+			if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+				dest = v
+				break
+			}
+		}
+		if dest == "" {
+			return s
+		}
+		s = dest
+	}
+
+	return s
+}
+
+// unwrapItems replaces the redirector URLs with their destination
+func unwrapItems(items []Match) {
+	for i := range items {
+		if v := unwrapURL(items[i].Value); v != items[i].Value {
+			items[i].Value = v
+			items[i].Type = classify(v)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// webhook events
+const (
+	eventBroken = "broken"
+	eventFound  = "found"
+)
+
+var errWebhook = errors.New("error posting to webhook")
+
+// webhookItem is a URL of a webhook event
+type webhookItem struct {
+	URL    string `json:"url"`
+	Status string `json:"status,omitempty"`
+	Source string `json:"source,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+// webhookEvent is the summary posted to the webhook, and the data of the
+// --webhook-template
+type webhookEvent struct {
+	Event string        `json:"event"`
+	Text  string        `json:"text"`
+	Items []webhookItem `json:"items"`
+}
+
+// validateWebhook checks the --webhook URL and --webhook-template
+func validateWebhook(rawURL, tmpl string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: --webhook needs an http or https URL", errInvalidFlag)
+	}
+	if tmpl == "" {
+		return nil
+	}
+	if _, err := parseWebhookTemplate(tmpl); err != nil {
+		return fmt.Errorf("%w: --webhook-template: %w", errInvalidFlag, err)
+	}
+
+	return nil
+}
+
+// parseWebhookTemplate parses the payload template, with a json function
+// to quote values
+func parseWebhookTemplate(s string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(s)
+}
+
+// newWebhookEvent returns the event of the items, with a summary text for
+// chat services
+func newWebhookEvent(event string, items []webhookItem) *webhookEvent {
+	title := fmt.Sprintf("%s: %d new URLs found", appName, len(items))
+	if event == eventBroken {
+		title = fmt.Sprintf("%s: %d newly broken links", appName, len(items))
+	}
+
+	lines := []string{title}
+	for _, it := range items {
+		s := it.URL
+		if it.Status != "" {
+			s = it.Status + " " + s
+		}
+		if it.Source != "" {
+			s += fmt.Sprintf(" (%s:%d)", it.Source, it.Line)
+		}
+		lines = append(lines, s)
+	}
+
+	return &webhookEvent{Event: event, Text: strings.Join(lines, "\n"), Items: items}
+}
+
+// webhookPayload returns the body posted for the event: the template when
+// given, the message format of Slack and Discord for their webhooks, and
+// the event as JSON for the others
+func webhookPayload(rawURL string, ev *webhookEvent) ([]byte, error) {
+	if webhookTmplFlag != "" {
+		t, err := parseWebhookTemplate(webhookTmplFlag)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, ev); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch host := strings.ToLower(u.Hostname()); {
+	case host == "hooks.slack.com":
+		return json.Marshal(map[string]string{"text": ev.Text})
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		// discord messages are limited to 2000 characters
+		text := []rune(ev.Text)
+		return json.Marshal(map[string]string{"content": string(text[:min(len(text), 2000)])})
+	}
+
+	return json.Marshal(ev)
+}
+
+// postWebhook posts the event to the --webhook URL
+func postWebhook(ev *webhookEvent) error {
+	body, err := webhookPayload(webhookFlag, ev)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errWebhook, err)
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(webhookFlag, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errWebhook, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", errWebhook, resp.Status)
+	}
+	log.Printf("webhook: %s event with %d urls posted", ev.Event, len(ev.Items))
+
+	return nil
+}
+
+// checkState is what a check run reported, to tell the next run what is new
+type checkState struct {
+	Seen   []string `json:"seen"`
+	Broken []string `json:"broken"`
+}
+
+// checkStatePath returns the path of the state of the check runs with the
+// same arguments and webhook
+func checkStatePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(webhookFlag + "\x00" + strings.Join(flag.Args(), "\x00")))
+
+	return filepath.Join(dir, "webhook-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// notifyCheck posts the links broken since the last run with the same
+// arguments, and the URLs not found then. The first run only posts the
+// broken links.
+func notifyCheck(items []Match) error {
+	path, err := checkStatePath()
+	if err != nil {
+		return err
+	}
+
+	var prev checkState
+	b, err := os.ReadFile(path)
+	first := errors.Is(err, os.ErrNotExist)
+	if err == nil {
+		err = json.Unmarshal(b, &prev)
+	}
+	if err != nil && !first {
+		return fmt.Errorf("error reading webhook state: %w", err)
+	}
+
+	seen, wasBroken := make(map[string]bool), make(map[string]bool)
+	for _, v := range prev.Seen {
+		seen[v] = true
+	}
+	for _, v := range prev.Broken {
+		wasBroken[v] = true
+	}
+
+	var state checkState
+	var broken, found []webhookItem
+	for i := range items {
+		m := &items[i]
+		state.Seen = append(state.Seen, m.Value)
+		it := webhookItem{URL: m.Value, Source: m.Source, Line: m.Line}
+		if m.Check != nil && m.Check.broken() {
+			state.Broken = append(state.Broken, m.Value)
+			if !wasBroken[m.Value] {
+				it.Status = m.Check.String()
+				broken = append(broken, it)
+			}
+		}
+		if !first && !seen[m.Value] {
+			found = append(found, it)
+		}
+	}
+
+	if len(broken) > 0 {
+		if err := postWebhook(newWebhookEvent(eventBroken, broken)); err != nil {
+			return err
+		}
+	}
+	if len(found) > 0 {
+		if err := postWebhook(newWebhookEvent(eventFound, found)); err != nil {
+			return err
+		}
+	}
+
+	if b, err = json.Marshal(state); err == nil {
+		err = os.WriteFile(path, b, 0o600)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing webhook state: %w", err)
+	}
+
+	return nil
+}
+
+// notifyFound posts the new URLs found in watch mode
+func notifyFound(urls []string) error {
+	if webhookFlag == "" || len(urls) == 0 {
+		return nil
+	}
+
+	items := make([]webhookItem, 0, len(urls))
+	for _, u := range urls {
+		items = append(items, webhookItem{URL: u})
+	}
+
+	return postWebhook(newWebhookEvent(eventFound, items))
+}
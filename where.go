@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var errWhere = errors.New("invalid --where expression")
+
+// whereFields are the URL components and item fields --where can test
+var whereFields = []string{
+	"url", "scheme", "user", "host", "port", "path", "query", "fragment",
+	"type", "source", "line", "count", "score",
+}
+
+var (
+	// whereCmpOps compare a field with a value, ~= and !~ with a regex
+	whereCmpOps = []string{"==", "!=", "~=", "!~", "^=", "$=", "<=", ">=", "<", ">"}
+	// whereOps are all the operators, the longest first so they are
+	// tokenized before their prefixes
+	whereOps = append(append([]string{"&&", "||"}, whereCmpOps...), "!", "(", ")")
+)
+
+// whereItem is the item a --where expression is evaluated against, its
+// URL is parsed when a component is needed
+type whereItem struct {
+	m      *Match
+	u      *url.URL
+	parsed bool
+}
+
+// field returns the value of the field of the item, "" when it has none
+func (w *whereItem) field(name string) string {
+	switch name {
+	case "url":
+		return w.m.Value
+	case "type":
+		return w.m.Type
+	case "source":
+		return w.m.Source
+	case "line":
+		return strconv.Itoa(w.m.Line)
+	case "count":
+		return strconv.Itoa(w.m.Count)
+	case "score":
+		return strconv.Itoa(confidence(w.m.Value))
+	}
+
+	if !w.parsed {
+		w.parsed = true
+		w.u, _ = parseURL(w.m.Value)
+	}
+	u := w.u
+	if u == nil {
+		return ""
+	}
+
+	// mailto:user@host
+	if u.Opaque != "" && (name == "user" || name == "host") {
+		user, host, _ := strings.Cut(u.Opaque, "@")
+		if name == "user" {
+			return user
+		}
+		return strings.ToLower(host)
+	}
+
+	switch name {
+	case "scheme":
+		return strings.ToLower(u.Scheme)
+	case "user":
+		return u.User.Username()
+	case "host":
+		return strings.ToLower(u.Hostname())
+	case "port":
+		return u.Port()
+	case "path":
+		return u.Path
+	case "query":
+		return u.RawQuery
+	case "fragment":
+		return u.Fragment
+	}
+
+	return ""
+}
+
+// whereExpr is a node of a --where expression
+type whereExpr interface {
+	eval(w *whereItem) bool
+}
+
+type (
+	whereAnd struct{ l, r whereExpr }
+	whereOr  struct{ l, r whereExpr }
+	whereNot struct{ e whereExpr }
+	// whereCmp compares a field with a value, re is set for ~= and !~
+	whereCmp struct {
+		field, op, value string
+		re               *regexp.Regexp
+	}
+)
+
+func (e whereAnd) eval(w *whereItem) bool { return e.l.eval(w) && e.r.eval(w) }
+func (e whereOr) eval(w *whereItem) bool  { return e.l.eval(w) || e.r.eval(w) }
+func (e whereNot) eval(w *whereItem) bool { return !e.e.eval(w) }
+
+func (e whereCmp) eval(w *whereItem) bool {
+	v := w.field(e.field)
+	switch e.op {
+	case "==":
+		return v == e.value
+	case "!=":
+		return v != e.value
+	case "~=":
+		return e.re.MatchString(v)
+	case "!~":
+		return !e.re.MatchString(v)
+	case "^=":
+		return strings.HasPrefix(v, e.value)
+	case "$=":
+		return strings.HasSuffix(v, e.value)
+	}
+
+	// numeric comparisons, false when the field is not a number
+	a, err := strconv.Atoi(v)
+	if err != nil {
+		return false
+	}
+	b, _ := strconv.Atoi(e.value)
+	switch e.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+
+	return false
+}
+
+// whereToken is a token of a --where expression, quoted is set for
+// strings so they are never taken as operators
+type whereToken struct {
+	text   string
+	pos    int
+	quoted bool
+}
+
+// tokenizeWhere splits the expression in operators, strings and words
+func tokenizeWhere(s string) ([]whereToken, error) {
+	var tokens []whereToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string at %d", errWhere, i+1)
+			}
+			tokens = append(tokens, whereToken{text: s[i+1 : i+1+end], pos: i + 1, quoted: true})
+			i += end + 2
+			continue
+		}
+
+		op := ""
+		for _, o := range whereOps {
+			if strings.HasPrefix(s[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, whereToken{text: op, pos: i + 1})
+			i += len(op)
+			continue
+		}
+
+		start := i
+		for i < len(s) && !strings.ContainsRune(" \t\n\"'&|=!~^$<>()", rune(s[i])) {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("%w: unexpected %q at %d", errWhere, s[i], i+1)
+		}
+		tokens = append(tokens, whereToken{text: s[start:i], pos: start + 1})
+	}
+
+	return tokens, nil
+}
+
+// whereParser parses the tokens of a --where expression
+type whereParser struct {
+	tokens []whereToken
+	i      int
+}
+
+// peek returns the next operator, "" at the end or when it is not one
+func (p *whereParser) peek() string {
+	if p.i >= len(p.tokens) || p.tokens[p.i].quoted {
+		return ""
+	}
+
+	return p.tokens[p.i].text
+}
+
+// next returns the next token, with ok unset at the end
+func (p *whereParser) next() (whereToken, bool) {
+	if p.i >= len(p.tokens) {
+		return whereToken{}, false
+	}
+	p.i++
+
+	return p.tokens[p.i-1], true
+}
+
+func (p *whereParser) or() (whereExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.i++
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = whereOr{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *whereParser) and() (whereExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.i++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = whereAnd{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *whereParser) unary() (whereExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.i++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return whereNot{e}, nil
+	case "(":
+		p.i++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.next(); !ok || t.quoted || t.text != ")" {
+			return nil, fmt.Errorf("%w: missing )", errWhere)
+		}
+		return e, nil
+	}
+
+	return p.cmp()
+}
+
+func (p *whereParser) cmp() (whereExpr, error) {
+	field, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected end, expected a field", errWhere)
+	}
+	if field.quoted || !inList(whereFields, field.text) {
+		return nil, fmt.Errorf("%w: unknown field %q at %d (valid: %s)",
+			errWhere, field.text, field.pos, strings.Join(whereFields, ", "))
+	}
+
+	op, ok := p.next()
+	if !ok || op.quoted || !inList(whereCmpOps, op.text) {
+		return nil, fmt.Errorf("%w: expected an operator after %s", errWhere, field.text)
+	}
+
+	value, ok := p.next()
+	if !ok || !value.quoted && inList(whereOps, value.text) {
+		return nil, fmt.Errorf("%w: expected a value after %s %s", errWhere, field.text, op.text)
+	}
+
+	e := whereCmp{field: field.text, op: op.text, value: value.text}
+	switch op.text {
+	case "~=", "!~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errWhere, err)
+		}
+		e.re = re
+	case "<", "<=", ">", ">=":
+		if _, err := strconv.Atoi(value.text); err != nil {
+			return nil, fmt.Errorf("%w: %s needs a number, got %q", errWhere, op.text, value.text)
+		}
+	}
+
+	return e, nil
+}
+
+// parseWhere parses the --where expression, nil when it is empty
+func parseWhere(s string) (whereExpr, error) {
+	tokens, err := tokenizeWhere(s)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+
+	p := &whereParser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.next(); ok {
+		return nil, fmt.Errorf("%w: unexpected %q at %d", errWhere, t.text, t.pos)
+	}
+
+	return e, nil
+}
+
+// whereUses reports whether the expression compares one of the fields
+func whereUses(e whereExpr, fields ...string) bool {
+	switch e := e.(type) {
+	case whereAnd:
+		return whereUses(e.l, fields...) || whereUses(e.r, fields...)
+	case whereOr:
+		return whereUses(e.l, fields...) || whereUses(e.r, fields...)
+	case whereNot:
+		return whereUses(e.e, fields...)
+	case whereCmp:
+		return inList(fields, e.field)
+	}
+
+	return false
+}
+
+// filterWhere keeps the items matching the expression
+func filterWhere(items []Match, e whereExpr) []Match {
+	result := items[:0]
+	for i := range items {
+		if e.eval(&whereItem{m: &items[i]}) {
+			result = append(result, items[i])
+		}
+	}
+
+	return result
+}
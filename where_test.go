@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	m := Match{Value: "https://user@a.example:8080/docs/page?q=1#top", Type: "url", Source: "notes.txt", Line: 12, Count: 3}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`host == "a.example"`, true},
+		{`host != "a.example"`, false},
+		{`scheme == https && port == 8080`, true},
+		{`user == user && fragment == top && query == 'q=1'`, true},
+		{`path ^= /docs`, true},
+		{`path $= /page`, true},
+		{`host ~= '^a\.'`, true},
+		{`host !~ example`, false},
+		{`source == "notes.txt"`, true},
+
+		// numbers
+		{`line > 10`, true},
+		{`line < 12`, false},
+		{`line <= 12 && line >= 12`, true},
+		{`count >= 4`, false},
+		// a string field is never a number
+		{`host > 1`, false},
+		// == compares the text
+		{`count == 03`, false},
+
+		// && binds tighter than ||
+		{`host == a.example || port == 1 && path == /x`, true},
+		{`port == 1 && path == /x || host == a.example`, true},
+		{`(host == a.example || port == 1) && path == /x`, false},
+		{`host == b.example || count == 3 && line == 12`, true},
+		{`host == b.example && count == 3 || line == 1`, false},
+		// ! binds tighter than &&
+		{`!host == b.example && port == 8080`, true},
+		{`!(host == a.example && port == 8080)`, false},
+		{`!!(port == 8080)`, true},
+		{`((host == a.example))`, true},
+
+		// quoted operators are values
+		{`query != "&&"`, true},
+		{`fragment == "||"`, false},
+	}
+	for _, tt := range tests {
+		e, err := parseWhere(tt.expr)
+		if err != nil {
+			t.Errorf("parseWhere(%s): %s", tt.expr, err)
+			continue
+		}
+		item := m
+		if got := e.eval(&whereItem{m: &item}); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseWhereMailto(t *testing.T) {
+	e, err := parseWhere(`user == bob && host == example.com`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := (Match{Value: "mailto:bob@Example.com"}); !e.eval(&whereItem{m: &m}) {
+		t.Errorf("the user and host of %s are not bob and example.com", m.Value)
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	for _, expr := range []string{
+		`host ==`,
+		`host`,
+		`== a`,
+		`hostname == a`,
+		`"host" == a`,
+		`host == "a`,
+		`host == a &&`,
+		`&& host == a`,
+		`(host == a`,
+		`host == a)`,
+		`host == a host == b`,
+		`host == &&`,
+		`host # a`,
+		`line > ten`,
+		`host ~= "("`,
+	} {
+		if _, err := parseWhere(expr); !errors.Is(err, errWhere) {
+			t.Errorf("parseWhere(%s): got %v, want %v", expr, err, errWhere)
+		}
+	}
+
+	if e, err := parseWhere(" \t"); e != nil || err != nil {
+		t.Errorf("blank expression: got %v, %v, want no expression", e, err)
+	}
+}
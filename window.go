@@ -0,0 +1,58 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// matchContext returns the match with up to n characters of the line on
+// each side, with the whitespace collapsed so it fits in one field
+func matchContext(line string, start, end, n int) string {
+	for i := 0; i < n && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(line[:start])
+		start -= size
+	}
+	for i := 0; i < n && end < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[end:])
+		end += size
+	}
+
+	return strings.Join(strings.Fields(line[start:end]), " ")
+}
+
+// customMatch returns the item of the custom regex match between start
+// and end, with its context when --capture-window is set
+func (o *Options) customMatch(line *inputLine, start, end int) Match {
+	item := strings.Split(removeNewlines(line.text[start:end]), " ")[0]
+	item = decodeEntities(o.Prefix + item + o.Suffix)
+	m := Match{
+		Value:  item,
+		Source: line.source,
+		Type:   classify(item),
+		Line:   line.num,
+	}
+	if o.Window > 0 {
+		m.Context = matchContext(line.text, start, end, o.Window)
+	}
+
+	return m
+}
+
+// findWithContext returns the matches of the regex with the context around
+// each one, across the lines of a source with --multiline
+func (o *Options) findWithContext(sources []source, regex string) []Match {
+	re := regexp.MustCompile(o.customPattern(regex))
+	var items []Match
+	blocks := o.inputBlocks(o.processInputData(sources))
+	for i := range blocks {
+		b := &blocks[i]
+		for _, loc := range re.FindAllStringIndex(b.text, -1) {
+			m := o.customMatch(&b.inputLine, loc[0], loc[1])
+			m.Line = b.lineAt(loc[0])
+			items = append(items, m)
+		}
+	}
+
+	return items
+}